@@ -2,10 +2,29 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
 )
 
+// Path is where Load reads and writes the configuration.
+const Path = "config.json"
+
+// DefaultSession is the account used when no sessions are configured. It
+// keeps the session files and data where a single account had them.
+const DefaultSession = "main"
+
+// Session is one WhatsApp account run by this process. An empty prefix
+// falls back to bot.prefix and an empty plugin list enables every plugin.
+type Session struct {
+	Name            string   `json:"name"`
+	Phone           string   `json:"phone"`
+	Prefix          string   `json:"prefix"`
+	Plugins         []string `json:"plugins"`
+	DisabledPlugins []string `json:"disabled_plugins"`
+	StoreDSN        string   `json:"store_dsn,omitempty"`
+}
+
 type Config struct {
 	Bot struct {
 		Name     string `json:"name"`
@@ -15,20 +34,55 @@ type Config struct {
 	} `json:"bot"`
 	
 	Database struct {
-		Path string `json:"path"`
+		Driver         string `json:"driver"`
+		Path           string `json:"path"`
+		Backups        int    `json:"backups"`
+		BackupInterval int    `json:"backup_interval"`
+		FlushInterval  int    `json:"flush_interval_ms"`
+		FlushThreshold int    `json:"flush_threshold"`
 	} `json:"database"`
 	
 	WhatsApp struct {
 		SessionPath string `json:"session_path"`
 		AutoReply   bool   `json:"auto_reply"`
+		AutoRead    string `json:"auto_read"`
 		LogLevel    string `json:"log_level"`
+		
+		GroupCacheTTL     int `json:"group_cache_ttl"`
+		ReconnectMaxDelay int `json:"reconnect_max_delay"`
+		OfflineQueueSize  int `json:"offline_queue_size"`
+		
+		Online bool `json:"online"`
+		
+		QRPNG      bool   `json:"qr_png"`
+		QRHTTPAddr string `json:"qr_http_addr"`
+		QRTimeout  int    `json:"qr_timeout"`
+		
+		PairTimeout int `json:"pair_timeout"`
+		
+		StoreDriver string `json:"store_driver"`
+		StoreDSN    string `json:"store_dsn"`
 	} `json:"whatsapp"`
 	
+	Queue struct {
+		GlobalRate   float64 `json:"global_rate"`
+		ChatInterval int     `json:"chat_interval_ms"`
+		Typing       bool    `json:"typing"`
+		MaxRetries   int     `json:"max_retries"`
+	} `json:"queue"`
+	
+	Sticker struct {
+		PackName  string `json:"pack_name"`
+		Publisher string `json:"publisher"`
+	} `json:"sticker"`
+	
 	Plugins struct {
 		Dir          string   `json:"dir"`
 		AutoLoad     bool     `json:"auto_load"`
 		DisabledList []string `json:"disabled_list"`
 	} `json:"plugins"`
+	
+	Sessions []Session `json:"sessions"`
 }
 
 func Load() (*Config, error) {
@@ -39,17 +93,43 @@ func Load() (*Config, error) {
 	cfg.Bot.Prefix = "!"
 	cfg.Bot.Version = "1.0.0"
 	
+	cfg.Database.Driver = "json"
 	cfg.Database.Path = "data/database.json"
+	cfg.Database.Backups = 5
+	cfg.Database.BackupInterval = 3600
+	cfg.Database.FlushInterval = 0
+	cfg.Database.FlushThreshold = 1000
 	
 	cfg.WhatsApp.SessionPath = "data/session"
 	cfg.WhatsApp.AutoReply = true
+	cfg.WhatsApp.AutoRead = "commands"
 	cfg.WhatsApp.LogLevel = "INFO"
+	cfg.WhatsApp.GroupCacheTTL = 600
+	cfg.WhatsApp.ReconnectMaxDelay = 120
+	cfg.WhatsApp.OfflineQueueSize = 100
+	cfg.WhatsApp.Online = true
+	cfg.WhatsApp.QRPNG = true
+	cfg.WhatsApp.QRHTTPAddr = ""
+	cfg.WhatsApp.QRTimeout = 180
+	cfg.WhatsApp.PairTimeout = 300
+	cfg.WhatsApp.StoreDriver = "sqlite3"
+	cfg.WhatsApp.StoreDSN = ""
+	
+	cfg.Queue.GlobalRate = 2
+	cfg.Queue.ChatInterval = 1000
+	cfg.Queue.Typing = true
+	cfg.Queue.MaxRetries = 3
+	
+	cfg.Sticker.PackName = "Yukii"
+	cfg.Sticker.Publisher = "Yukii Bot"
 	
 	cfg.Plugins.Dir = "plugins"
 	cfg.Plugins.AutoLoad = true
 	cfg.Plugins.DisabledList = []string{}
 	
-	configPath := "config.json"
+	cfg.Sessions = []Session{}
+	
+	configPath := Path
 	if _, err := os.Stat(configPath); os.IsNotExist(err) {
 		os.MkdirAll("data", 0755)
 		os.MkdirAll(cfg.Plugins.Dir, 0755)
@@ -72,6 +152,56 @@ func Load() (*Config, error) {
 	return cfg, nil
 }
 
+// GetSessions returns the configured sessions, or just the default one.
+func (cfg *Config) GetSessions() []Session {
+	if len(cfg.Sessions) == 0 {
+		return []Session{{Name: DefaultSession}}
+	}
+	return cfg.Sessions
+}
+
+// SessionDir is where the WhatsApp session of an account is stored.
+func (cfg *Config) SessionDir(name string) string {
+	if name == DefaultSession {
+		return cfg.WhatsApp.SessionPath
+	}
+	return filepath.Join(cfg.WhatsApp.SessionPath, name)
+}
+
+// SessionStoreFile is the SQLite session store of an account.
+func (cfg *Config) SessionStoreFile(name string) string {
+	return filepath.Join(cfg.SessionDir(name), "session.db")
+}
+
+// SessionStore returns the database driver and DSN of an account's session
+// store. SQLite stores always live in the session directory. A Postgres
+// database holds a single account, so every account other than the
+// default one needs its own store_dsn.
+func (cfg *Config) SessionStore(session Session) (string, string, error) {
+	switch cfg.WhatsApp.StoreDriver {
+	case "", "sqlite", "sqlite3":
+		return "sqlite3", fmt.Sprintf("file:%s?_foreign_keys=on", cfg.SessionStoreFile(session.Name)), nil
+	case "postgres", "postgresql":
+		dsn := session.StoreDSN
+		if dsn == "" && session.Name == DefaultSession {
+			dsn = cfg.WhatsApp.StoreDSN
+		}
+		if dsn == "" {
+			return "", "", fmt.Errorf("session %s has no store_dsn for postgres", session.Name)
+		}
+		return "postgres", dsn, nil
+	}
+	return "", "", fmt.Errorf("unsupported store_driver %q, use sqlite3 or postgres", cfg.WhatsApp.StoreDriver)
+}
+
+// SessionPrefix returns the command prefix of a session.
+func (cfg *Config) SessionPrefix(session Session) string {
+	if session.Prefix != "" {
+		return session.Prefix
+	}
+	return cfg.Bot.Prefix
+}
+
 func Save(cfg *Config, path string) error {
 	data, err := json.MarshalIndent(cfg, "", "  ")
 	if err != nil {
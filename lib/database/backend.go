@@ -0,0 +1,13 @@
+package database
+
+// Backend persists the document tree of a Database. Reads are served from
+// memory, so a backend only loads once and then writes changes.
+type Backend interface {
+	// Load returns the stored tree.
+	Load() (map[string]interface{}, error)
+	// Save persists data after the values under the changed paths were
+	// set or deleted. An empty path means the whole tree changed. Backends
+	// storing a single document may ignore the paths.
+	Save(data map[string]interface{}, changed [][]string) error
+	Close() error
+}
@@ -2,122 +2,223 @@ package database
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
+	"time"
+
+	"yukii-bot/lib/logger"
 
 	"github.com/tidwall/gjson"
-	"github.com/tidwall/sjson"
 )
 
+// Database is a view on a document store. Views made with Namespace share
+// the store but keep their keys under their own prefix.
 type Database struct {
-	path string
-	data map[string]interface{}
-	mu   sync.RWMutex
+	*store
+	prefix string
+}
+
+// store keeps the whole tree in memory and writes changes through its
+// backend, right away or in batches in write-behind mode.
+type store struct {
+	backend Backend
+	data    map[string]interface{}
+	mu      sync.RWMutex
+
+	pending        map[string][]string
+	flushInterval  time.Duration
+	flushThreshold int
+	flushNow       chan struct{}
+	stop           chan struct{}
+	stopped        chan struct{}
+}
+
+// Options configure how a Database stores its data.
+type Options struct {
+	// Driver is "json" to keep everything in one file or "sqlite" to store
+	// records in a SQLite file.
+	Driver string
+	Path   string
+	// Backups is how many copies of the JSON file to keep, taken at most
+	// once per BackupInterval. 0 disables backups.
+	Backups        int
+	BackupInterval time.Duration
+	// FlushInterval enables write-behind: changes are kept in memory and
+	// written at most this often, or as soon as FlushThreshold changes
+	// are pending. 0 writes every change right away.
+	FlushInterval  time.Duration
+	FlushThreshold int
 }
 
+// Init opens a JSON file database.
 func Init(path string) (*Database, error) {
-	db := &Database{
-		path: path,
-		data: make(map[string]interface{}),
+	return Open(Options{Driver: "json", Path: path})
+}
+
+// Open opens a database. A SQLite database starts with the data of the
+// JSON file next to it, so switching drivers keeps existing data.
+func Open(opts Options) (*Database, error) {
+	var backend Backend
+	switch opts.Driver {
+	case "", "json":
+		b, err := newJSONBackend(opts.Path, opts.Backups, opts.BackupInterval)
+		if err != nil {
+			return nil, err
+		}
+		backend = b
+	case "sqlite", "sqlite3":
+		path := opts.Path
+		jsonPath := strings.TrimSuffix(path, filepath.Ext(path)) + ".json"
+		if path == jsonPath {
+			path = strings.TrimSuffix(path, ".json") + ".db"
+		}
+		b, err := newSQLiteBackend(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := importJSON(b, jsonPath); err != nil {
+			b.Close()
+			return nil, err
+		}
+		backend = b
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q, use json or sqlite", opts.Driver)
 	}
 
-	dir := filepath.Dir(path)
-	if err := os.MkdirAll(dir, 0755); err != nil {
+	data, err := backend.Load()
+	if err != nil {
+		backend.Close()
 		return nil, err
 	}
-
-	if _, err := os.Stat(path); err == nil {
-		if err := db.load(); err != nil {
-			return nil, err
-		}
-	} else {
-		if err := db.save(); err != nil {
+	changed := append(migrateSplitKeys(data), migrateNumericKeys(data)...)
+	if len(changed) > 0 {
+		if err := backend.Save(data, changed); err != nil {
+			backend.Close()
 			return nil, err
 		}
 	}
-	
-	return db, nil
+
+	s := &store{
+		backend: backend,
+		data:    data,
+	}
+	if opts.FlushInterval > 0 {
+		s.startWriteBehind(opts.FlushInterval, opts.FlushThreshold)
+	}
+	return &Database{store: s}, nil
 }
 
-func (db *Database) load() error {
-	db.mu.Lock()
-	defer db.mu.Unlock()
-	
-	data, err := os.ReadFile(db.path)
+// importJSON fills an empty SQLite database from a JSON file database.
+func importJSON(b *sqliteBackend, path string) error {
+	empty, err := b.empty()
+	if err != nil || !empty {
+		return err
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil
+	}
+
+	source, err := newJSONBackend(path, 0, 0)
 	if err != nil {
 		return err
 	}
-	
+	data, err := source.Load()
+	if err != nil {
+		return fmt.Errorf("failed to import %s: %w", path, err)
+	}
 	if len(data) == 0 {
-		db.data = make(map[string]interface{})
 		return nil
 	}
-	
-	return json.Unmarshal(data, &db.data)
+
+	logger.Warning("📦 Importing %s into %s. From now on data is stored there and %s is no longer updated", path, b.path, path)
+	return b.Save(data, [][]string{{}})
 }
 
-func (db *Database) save() error {
-	data, err := json.MarshalIndent(db.data, "", "  ")
-	if err != nil {
-		return err
+// Namespace returns a view that keeps every key under accounts.<name>, so
+// several accounts can use the same keys in one database file.
+func (db *Database) Namespace(name string) *Database {
+	return &Database{
+		store:  db.store,
+		prefix: db.prefix + "accounts." + EscapeKey(name) + ".",
 	}
-	
-	return os.WriteFile(db.path, data, 0644)
+}
+
+// save persists the tree after a change below path, or below the first
+// key of a path that couldn't be split.
+func (db *Database) save(changed []string) error {
+	if db.pending != nil {
+		db.markPending(changed)
+		return nil
+	}
+	return db.backend.Save(db.data, [][]string{changed})
 }
 
 func (db *Database) Set(key string, value interface{}) error {
 	db.mu.Lock()
 	defer db.mu.Unlock()
 	
-	jsonData, err := json.Marshal(db.data)
-	if err != nil {
-		return err
-	}
-	
-	jsonData, err = sjson.SetBytes(jsonData, key, value)
-	if err != nil {
-		return err
-	}
-	
-	if err := json.Unmarshal(jsonData, &db.data); err != nil {
-		return err
-	}
-	
-	return db.save()
+	return db.set(db.prefix+key, value)
 }
 
 func (db *Database) Get(key string) gjson.Result {
 	db.mu.RLock()
 	defer db.mu.RUnlock()
 	
-	jsonData, err := json.Marshal(db.data)
+	return db.get(db.prefix + key)
+}
+
+// set stores a value at a full key. The caller holds the write lock.
+func (db *Database) set(key string, value interface{}) error {
+	if keys, objects, ok := splitPath(key); ok {
+		normalized, err := normalize(value)
+		if err != nil {
+			return err
+		}
+		if assign(db.data, keys, objects, normalized) {
+			return db.save(keys)
+		}
+	}
+	
+	first, err := setSlow(db.data, key, value)
 	if err != nil {
-		return gjson.Result{}
+		return err
+	}
+	return db.save(pathOf(first))
+}
+
+// get reads the value at a full key. The caller holds a lock.
+func (db *Database) get(key string) gjson.Result {
+	if keys, _, ok := splitPath(key); ok {
+		value, found, ok := lookup(db.data, keys)
+		if ok {
+			if !found {
+				return gjson.Result{}
+			}
+			return resultOf(value)
+		}
 	}
 	
-	return gjson.GetBytes(jsonData, key)
+	return getSlow(db.data, key)
 }
 
 func (db *Database) Delete(key string) error {
+	key = db.prefix + key
+	
 	db.mu.Lock()
 	defer db.mu.Unlock()
 	
-	jsonData, err := json.Marshal(db.data)
-	if err != nil {
-		return err
+	if keys, _, ok := splitPath(key); ok && remove(db.data, keys) {
+		return db.save(keys)
 	}
 	
-	jsonData, err = sjson.DeleteBytes(jsonData, key)
+	first, err := deleteSlow(db.data, key)
 	if err != nil {
 		return err
 	}
-	
-	if err := json.Unmarshal(jsonData, &db.data); err != nil {
-		return err
-	}
-	
-	return db.save()
+	return db.save(pathOf(first))
 }
 
 func (db *Database) Has(key string) bool {
@@ -125,30 +226,72 @@ func (db *Database) Has(key string) bool {
 }
 
 func (db *Database) GetAll() map[string]interface{} {
+	if db.prefix != "" {
+		result, _ := db.namespaceRoot().Value().(map[string]interface{})
+		if result == nil {
+			result = make(map[string]interface{})
+		}
+		return result
+	}
+	
 	db.mu.RLock()
 	defer db.mu.RUnlock()
 
-	result := make(map[string]interface{})
-	for k, v := range db.data {
-		result[k] = v
+	// Writes change nested maps in place, so callers get a deep copy.
+	copied, err := normalize(db.data)
+	result, _ := copied.(map[string]interface{})
+	if err != nil || result == nil {
+		result = make(map[string]interface{})
 	}
 	
 	return result
 }
 
 func (db *Database) Clear() error {
+	if db.prefix != "" {
+		root := &Database{store: db.store}
+		return root.Delete(strings.TrimSuffix(db.prefix, "."))
+	}
+	
 	db.mu.Lock()
 	defer db.mu.Unlock()
 	
 	db.data = make(map[string]interface{})
-	return db.save()
+	return db.save(nil)
 }
 
+// Close writes pending changes and closes the backend.
 func (db *Database) Close() error {
+	db.stopWriteBehind()
+	
 	db.mu.Lock()
 	defer db.mu.Unlock()
 	
-	return db.save()
+	err := db.flush()
+	if closeErr := db.backend.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (db *Database) namespaceRoot() gjson.Result {
+	root := &Database{store: db.store}
+	return root.Get(strings.TrimSuffix(db.prefix, "."))
+}
+
+func pathOf(key string) []string {
+	if key == "" {
+		return nil
+	}
+	return []string{key}
+}
+
+func resultOf(value interface{}) gjson.Result {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return gjson.Result{}
+	}
+	return gjson.ParseBytes(raw)
 }
 
 func (db *Database) GetString(key string) string {
@@ -176,25 +319,33 @@ func (db *Database) GetMap(key string) map[string]gjson.Result {
 }
 
 func (db *Database) SetUser(jid, key string, value interface{}) error {
-	return db.Set("users."+jid+"."+key, value)
+	return db.Set(userKey(jid)+"."+key, value)
 }
 
 func (db *Database) GetUser(jid, key string) gjson.Result {
-	return db.Get("users." + jid + "." + key)
+	return db.Get(userKey(jid) + "." + key)
+}
+
+func (db *Database) DeleteUser(jid, key string) error {
+	return db.Delete(userKey(jid) + "." + key)
 }
 
 func (db *Database) HasUser(jid string) bool {
-	return db.Has("users." + jid)
+	return db.Has(userKey(jid))
 }
 
 func (db *Database) SetGroup(jid, key string, value interface{}) error {
-	return db.Set("groups."+jid+"."+key, value)
+	return db.Set(groupKey(jid)+"."+key, value)
 }
 
 func (db *Database) GetGroup(jid, key string) gjson.Result {
-	return db.Get("groups." + jid + "." + key)
+	return db.Get(groupKey(jid) + "." + key)
+}
+
+func (db *Database) DeleteGroup(jid, key string) error {
+	return db.Delete(groupKey(jid) + "." + key)
 }
 
 func (db *Database) HasGroup(jid string) bool {
-	return db.Has("groups." + jid)
+	return db.Has(groupKey(jid))
 }
\ No newline at end of file
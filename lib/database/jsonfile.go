@@ -0,0 +1,210 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"yukii-bot/lib/logger"
+)
+
+const backupTimeFormat = "20060102-150405"
+
+// errEmptyFile is what a crash during a plain, non-atomic write leaves.
+var errEmptyFile = errors.New("file is empty")
+
+// jsonBackend keeps the whole tree in one JSON file, rewritten on every
+// save. It's easy to read and edit by hand but slow for big databases.
+//
+// Writes go to a temporary file that replaces the database only once it's
+// fully on disk, so a crash leaves either the old or the new file. Copies
+// are kept in a backups directory next to it, and a file that fails to
+// load anyway is replaced by the newest backup that loads.
+type jsonBackend struct {
+	path           string
+	backups        int
+	backupInterval time.Duration
+	lastBackup     time.Time
+}
+
+func newJSONBackend(path string, backups int, backupInterval time.Duration) (*jsonBackend, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	b := &jsonBackend{
+		path:           path,
+		backups:        backups,
+		backupInterval: backupInterval,
+	}
+	if files := b.backupFiles(); len(files) > 0 {
+		if info, err := os.Stat(files[0]); err == nil {
+			b.lastBackup = info.ModTime()
+		}
+	}
+	return b, nil
+}
+
+func (b *jsonBackend) backupDir() string {
+	return filepath.Join(filepath.Dir(b.path), "backups")
+}
+
+// backupFiles lists the backups, newest first.
+func (b *jsonBackend) backupFiles() []string {
+	name := strings.TrimSuffix(filepath.Base(b.path), filepath.Ext(b.path))
+	files, _ := filepath.Glob(filepath.Join(b.backupDir(), name+"-*.json"))
+	// The timestamp in the name sorts by time.
+	sort.Sort(sort.Reverse(sort.StringSlice(files)))
+	return files
+}
+
+func (b *jsonBackend) Load() (map[string]interface{}, error) {
+	b.removeTempFiles()
+
+	data, err := readJSONFile(b.path)
+	if os.IsNotExist(err) {
+		data = make(map[string]interface{})
+		return data, b.Save(data, nil)
+	}
+	if err == nil {
+		return data, nil
+	}
+	if errors.Is(err, errEmptyFile) && len(b.backupFiles()) == 0 {
+		return make(map[string]interface{}), nil
+	}
+
+	logger.Error("Database %s is damaged: %v", b.path, err)
+	for _, backup := range b.backupFiles() {
+		data, backupErr := readJSONFile(backup)
+		if backupErr != nil {
+			logger.Warning("Backup %s is damaged too: %v", backup, backupErr)
+			continue
+		}
+
+		corrupt := fmt.Sprintf("%s.corrupt-%s", b.path, time.Now().Format(backupTimeFormat))
+		if err := os.Rename(b.path, corrupt); err != nil {
+			return nil, err
+		}
+		if err := writeFileAtomic(b.path, mustMarshal(data)); err != nil {
+			return nil, err
+		}
+		logger.Warning("♻️ Restored the database from %s, the damaged file was kept as %s", backup, corrupt)
+		return data, nil
+	}
+	return nil, fmt.Errorf("%s is damaged and no backup could be loaded: %w", b.path, err)
+}
+
+func (b *jsonBackend) Save(data map[string]interface{}, changed [][]string) error {
+	raw, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(b.path, raw); err != nil {
+		return err
+	}
+
+	if b.backups > 0 && time.Since(b.lastBackup) >= b.backupInterval {
+		if err := b.backup(raw); err != nil {
+			logger.Error("Failed to back up the database: %v", err)
+		}
+	}
+	return nil
+}
+
+// backup stores a copy of a saved database and drops the oldest copies
+// beyond the configured count.
+func (b *jsonBackend) backup(raw []byte) error {
+	if err := os.MkdirAll(b.backupDir(), 0755); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	name := strings.TrimSuffix(filepath.Base(b.path), filepath.Ext(b.path))
+	path := filepath.Join(b.backupDir(), fmt.Sprintf("%s-%s.json", name, now.Format(backupTimeFormat)))
+	if err := writeFileAtomic(path, raw); err != nil {
+		return err
+	}
+	b.lastBackup = now
+
+	files := b.backupFiles()
+	for i := b.backups; i < len(files); i++ {
+		os.Remove(files[i])
+	}
+	return nil
+}
+
+// removeTempFiles cleans up after writes interrupted by a crash.
+func (b *jsonBackend) removeTempFiles() {
+	files, _ := filepath.Glob(filepath.Join(filepath.Dir(b.path), "."+filepath.Base(b.path)+".tmp-*"))
+	for _, file := range files {
+		os.Remove(file)
+	}
+}
+
+func (b *jsonBackend) Close() error {
+	return nil
+}
+
+func readJSONFile(path string) (map[string]interface{}, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(raw) == 0 {
+		return nil, errEmptyFile
+	}
+	data := make(map[string]interface{})
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func mustMarshal(data map[string]interface{}) []byte {
+	raw, _ := json.MarshalIndent(data, "", "  ")
+	return raw
+}
+
+// writeFileAtomic replaces a file so that readers and crashes only ever
+// see the old or the new content: the data is written and synced to a
+// temporary file, which is then renamed over the target.
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+
+	// Persist the rename itself. Not every platform can sync directories.
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
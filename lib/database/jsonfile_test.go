@@ -0,0 +1,94 @@
+package database
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func openJSON(t *testing.T, path string) *Database {
+	t.Helper()
+
+	db, err := Open(Options{Driver: "json", Path: path, Backups: 3})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	return db
+}
+
+func TestPartialWriteRecoversFromBackup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "database.json")
+
+	db := openJSON(t, path)
+	if err := db.Set("bot.name", "Yukii"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Set("bot.commands", 42); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	// A crash halfway through a plain write leaves a truncated file.
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, raw[:len(raw)/2], 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	db = openJSON(t, path)
+	defer db.Close()
+
+	if got := db.Get("bot.name").String(); got != "Yukii" {
+		t.Errorf("bot.name = %q, want Yukii", got)
+	}
+	if got := db.Get("bot.commands").Int(); got != 42 {
+		t.Errorf("bot.commands = %d, want 42", got)
+	}
+
+	damaged, _ := filepath.Glob(path + ".corrupt-*")
+	if len(damaged) != 1 {
+		t.Errorf("damaged file kept %d times, want once", len(damaged))
+	}
+}
+
+func TestInterruptedWriteKeepsDatabase(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "database.json")
+
+	db := openJSON(t, path)
+	if err := db.Set("bot.name", "Yukii"); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	// A crash before the rename leaves only a partial temporary file.
+	tmp := filepath.Join(dir, ".database.json.tmp-123")
+	if err := os.WriteFile(tmp, []byte(`{"bot": {"na`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	db = openJSON(t, path)
+	defer db.Close()
+
+	if got := db.Get("bot.name").String(); got != "Yukii" {
+		t.Errorf("bot.name = %q, want Yukii", got)
+	}
+	if _, err := os.Stat(tmp); !os.IsNotExist(err) {
+		t.Errorf("temporary file was not removed")
+	}
+}
+
+func TestDamagedWithoutBackupFails(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "database.json")
+	if err := os.WriteFile(path, []byte(`{"bot": `), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := Open(Options{Driver: "json", Path: path})
+	if err == nil || !strings.Contains(err.Error(), "no backup") {
+		t.Fatalf("err = %v, want damaged database error", err)
+	}
+}
@@ -0,0 +1,155 @@
+package database
+
+import (
+	"strconv"
+	"strings"
+
+	"yukii-bot/lib/logger"
+)
+
+// splitServers are the JID servers that contain dots. Before user and
+// group keys were escaped, a record of 123@s.whatsapp.net ended up at
+// users.123@s.whatsapp.net as three nested objects.
+var splitServers = []string{"s.whatsapp.net", "g.us", "c.us"}
+
+// migrateSplitKeys moves records that were split at the dots of their JID
+// back under the full JID, in the root and in every account namespace. It
+// returns the paths it changed.
+func migrateSplitKeys(data map[string]interface{}) [][]string {
+	var changed [][]string
+	migrate := func(root map[string]interface{}, prefix []string) {
+		for _, collection := range []string{"users", "groups"} {
+			records, isMap := root[collection].(map[string]interface{})
+			if !isMap {
+				continue
+			}
+			for _, key := range joinSplitRecords(records) {
+				path := append(prefix[:len(prefix):len(prefix)], collection, key)
+				changed = append(changed, path)
+			}
+		}
+	}
+
+	migrate(data, nil)
+	if accounts, isMap := data["accounts"].(map[string]interface{}); isMap {
+		for name, account := range accounts {
+			if account, isMap := account.(map[string]interface{}); isMap {
+				migrate(account, []string{"accounts", name})
+			}
+		}
+	}
+
+	if len(changed) > 0 {
+		logger.Info("📦 Moved %d records stored under split JIDs", len(changed)/2)
+	}
+	return changed
+}
+
+// joinSplitRecords fixes the records of one collection and returns the
+// keys it removed and created.
+func joinSplitRecords(records map[string]interface{}) []string {
+	var changed []string
+	for key, value := range records {
+		at := strings.IndexByte(key, '@')
+		node, isMap := value.(map[string]interface{})
+		if at < 0 || !isMap {
+			continue
+		}
+
+		host := key[at+1:]
+		for _, server := range splitServers {
+			if !strings.HasPrefix(server, host+".") {
+				continue
+			}
+			parts := strings.Split(strings.TrimPrefix(server, host+"."), ".")
+			record, found, ok := lookup(node, parts)
+			fields, isRecord := record.(map[string]interface{})
+			if !found || !ok || !isRecord {
+				continue
+			}
+
+			full := key[:at+1] + server
+			target, isMap := records[full].(map[string]interface{})
+			if !isMap {
+				target = make(map[string]interface{})
+				records[full] = target
+			}
+			// Values already stored under the full JID are newer.
+			for name, field := range fields {
+				if _, exists := target[name]; !exists {
+					target[name] = field
+				}
+			}
+
+			prune(node, parts)
+			if len(node) == 0 {
+				delete(records, key)
+			}
+			changed = append(changed, key, full)
+		}
+	}
+	return changed
+}
+
+// prune deletes the value at keys and the objects left empty above it.
+func prune(node map[string]interface{}, keys []string) {
+	if len(keys) > 1 {
+		if child, isMap := node[keys[0]].(map[string]interface{}); isMap {
+			prune(child, keys[1:])
+			if len(child) > 0 {
+				return
+			}
+		}
+	}
+	delete(node, keys[0])
+}
+
+// migrateNumericKeys turns arrays that sjson made out of numeric keys back
+// into objects. Setting counts.42 on a missing counts used to create an
+// array of 42 nulls followed by the value; such arrays start with null and
+// are nearly all nulls. The kept entries are keyed by their index, which was
+// the original key. It returns the paths it changed.
+func migrateNumericKeys(data map[string]interface{}) [][]string {
+	var changed [][]string
+	var walk func(node map[string]interface{}, path []string)
+	walk = func(node map[string]interface{}, path []string) {
+		for key, value := range node {
+			keyPath := append(path[:len(path):len(path)], key)
+			switch value := value.(type) {
+			case map[string]interface{}:
+				walk(value, keyPath)
+			case []interface{}:
+				if object, ok := indexedObject(value); ok {
+					node[key] = object
+					changed = append(changed, keyPath)
+					walk(object, keyPath)
+				}
+			}
+		}
+	}
+	walk(data, nil)
+
+	for _, path := range changed {
+		logger.Warning("📦 %s was stored as an array of numeric keys, turned it back into an object", strings.Join(path, "."))
+	}
+	return changed
+}
+
+// indexedObject converts a sparse array left by a numeric key into an
+// object. ok is false for arrays that look like real lists.
+func indexedObject(array []interface{}) (map[string]interface{}, bool) {
+	if len(array) < 2 || array[0] != nil {
+		return nil, false
+	}
+
+	object := make(map[string]interface{})
+	for i, value := range array {
+		if value != nil {
+			object[strconv.Itoa(i)] = value
+		}
+	}
+	if len(object) == 0 || len(object)*10 > len(array) {
+		return nil, false
+	}
+	return object, true
+}
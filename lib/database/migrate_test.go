@@ -0,0 +1,171 @@
+package database
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// splitDatabase is what unescaped JIDs left behind: records nested at the
+// dots of their server, next to an unrelated global key.
+const splitDatabase = `{
+  "users": {
+    "6281234567890@s": {"whatsapp": {"net": {"points": 10, "name": "old"}}},
+    "6289876543210@s.whatsapp.net": {"name": "kept"},
+    "6289876543210@s": {"whatsapp": {"net": {"name": "split", "xp": 5}}},
+    "123456789012345@lid": {"points": 3}
+  },
+  "groups": {
+    "120363025246125888@g": {"us": {"welcome": {"enabled": true}}}
+  },
+  "accounts": {
+    "second": {
+      "users": {
+        "6281111111111@s": {"whatsapp": {"net": {"points": 7}}}
+      }
+    }
+  },
+  "global": {"total": 1}
+}`
+
+func TestMigrateSplitKeys(t *testing.T) {
+	for _, driver := range []string{"json", "sqlite"} {
+		t.Run(driver, func(t *testing.T) {
+			dir := t.TempDir()
+			// The SQLite database imports the JSON file next to it.
+			path := filepath.Join(dir, "database.json")
+			if err := os.WriteFile(path, []byte(splitDatabase), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			db, err := Open(Options{Driver: driver, Path: path})
+			if err != nil {
+				t.Fatalf("open: %v", err)
+			}
+			checkMigrated(t, db)
+			db.Close()
+
+			// The migration was saved, not only applied in memory.
+			var backend Backend
+			if driver == "json" {
+				backend, err = newJSONBackend(path, 0, 0)
+			} else {
+				backend, err = newSQLiteBackend(filepath.Join(dir, "database.db"))
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer backend.Close()
+			data, err := backend.Load()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if changed := migrateSplitKeys(data); len(changed) > 0 {
+				t.Errorf("stored data still has split records: %q", changed)
+			}
+			checkMigrated(t, &Database{store: &store{backend: backend, data: data}})
+		})
+	}
+}
+
+func checkMigrated(t *testing.T, db *Database) {
+	t.Helper()
+
+	if got := db.GetUser("6281234567890@s.whatsapp.net", "points").Int(); got != 10 {
+		t.Errorf("points = %d, want 10", got)
+	}
+	if got := db.GetUser("6289876543210@s.whatsapp.net", "name").String(); got != "kept" {
+		t.Errorf("name = %q, the value under the full JID should win", got)
+	}
+	if got := db.GetUser("6289876543210@s.whatsapp.net", "xp").Int(); got != 5 {
+		t.Errorf("xp = %d, want 5", got)
+	}
+	if got := db.GetUser("123456789012345@lid", "points").Int(); got != 3 {
+		t.Errorf("lid points = %d, want 3", got)
+	}
+	if !db.GetGroup("120363025246125888@g.us", "welcome.enabled").Bool() {
+		t.Errorf("group lost welcome.enabled")
+	}
+	if got := db.Namespace("second").GetUser("6281111111111@s.whatsapp.net", "points").Int(); got != 7 {
+		t.Errorf("account points = %d, want 7", got)
+	}
+	if got := db.Get("global.total").Int(); got != 1 {
+		t.Errorf("global.total = %d, want 1", got)
+	}
+
+	for key := range db.GetMap("users") {
+		if key == "6281234567890@s" || key == "6289876543210@s" {
+			t.Errorf("split record %q is still there", key)
+		}
+	}
+	if db.GetMap("groups")["120363025246125888@g"].Exists() {
+		t.Errorf("split group record is still there")
+	}
+}
+
+// numericDatabase is what sjson left for numeric keys set on missing
+// parents: arrays padded with nulls up to the key.
+var numericDatabase = `{
+  "counts": [` + strings.Repeat("null,", 42) + `5],
+  "groups": {
+    "120363025246125888@g.us": {
+      "moderation_warnings": [` + strings.Repeat("null,", 20) + `1,null,2]
+    }
+  },
+  "tags": ["a", null, "b"],
+  "empty": [null, null]
+}`
+
+func TestMigrateNumericKeys(t *testing.T) {
+	for _, driver := range []string{"json", "sqlite"} {
+		t.Run(driver, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "database.json")
+			if err := os.WriteFile(path, []byte(numericDatabase), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			db, err := Open(Options{Driver: driver, Path: path})
+			if err != nil {
+				t.Fatalf("open: %v", err)
+			}
+
+			if !db.Get("counts").IsObject() {
+				t.Errorf("counts = %s, want an object", db.Get("counts").Raw)
+			}
+			if got := db.Get("counts." + EscapeKey("42")).Int(); got != 5 {
+				t.Errorf("counts.42 = %d, want 5", got)
+			}
+			warnings := db.GetGroup("120363025246125888@g.us", "moderation_warnings")
+			if !warnings.IsObject() || warnings.Get("20").Int() != 1 || warnings.Get("22").Int() != 2 {
+				t.Errorf("warnings = %s", warnings.Raw)
+			}
+			if got := db.Get("tags").Raw; got != `["a",null,"b"]` {
+				t.Errorf("tags = %s, a real list must stay as it is", got)
+			}
+			if !db.Get("empty").IsArray() {
+				t.Errorf("empty = %s, want the array", db.Get("empty").Raw)
+			}
+			db.Close()
+
+			// The migration was saved, not only applied in memory.
+			var backend Backend
+			if driver == "json" {
+				backend, err = newJSONBackend(path, 0, 0)
+			} else {
+				backend, err = newSQLiteBackend(strings.TrimSuffix(path, ".json") + ".db")
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer backend.Close()
+			data, err := backend.Load()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if changed := migrateNumericKeys(data); len(changed) > 0 {
+				t.Errorf("stored data still has numeric arrays: %q", changed)
+			}
+		})
+	}
+}
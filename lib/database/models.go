@@ -0,0 +1,155 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Roles of a user. Plugins may define more, the database only stores them.
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+	RoleOwner = "owner"
+)
+
+// User is the typed record under users.<jid>. Plugins can still keep their
+// own fields in the same record with SetUser; saving a User leaves them
+// alone.
+type User struct {
+	JID        string                 `json:"-"`
+	Name       string                 `json:"name"`
+	Role       string                 `json:"role"`
+	Banned     bool                   `json:"banned"`
+	Premium    bool                   `json:"premium"`
+	XP         int64                  `json:"xp"`
+	Warnings   int                    `json:"warnings"`
+	Settings   map[string]interface{} `json:"settings"`
+	Registered time.Time              `json:"registered"`
+}
+
+// Group is the typed record under groups.<jid>. Moderation settings and
+// warnings stay with the moderation package, which updates them on its own.
+type Group struct {
+	JID        string                 `json:"-"`
+	Name       string                 `json:"name"`
+	Banned     bool                   `json:"banned"`
+	Premium    bool                   `json:"premium"`
+	Settings   map[string]interface{} `json:"settings"`
+	Registered time.Time              `json:"registered"`
+}
+
+// NewUser returns the defaults of a user that has no record yet.
+func NewUser(jid string) *User {
+	return &User{
+		JID:      jid,
+		Role:     RoleUser,
+		Settings: make(map[string]interface{}),
+	}
+}
+
+// NewGroup returns the defaults of a group that has no record yet.
+func NewGroup(jid string) *Group {
+	return &Group{
+		JID:      jid,
+		Settings: make(map[string]interface{}),
+	}
+}
+
+// IsNew reports whether the user was never saved.
+func (u *User) IsNew() bool {
+	return u.Registered.IsZero()
+}
+
+// IsNew reports whether the group was never saved.
+func (g *Group) IsNew() bool {
+	return g.Registered.IsZero()
+}
+
+func userKey(jid string) string {
+	return "users." + EscapeKey(jid)
+}
+
+func groupKey(jid string) string {
+	return "groups." + EscapeKey(jid)
+}
+
+// LoadUser returns the record of a user, or the defaults if there is none.
+func (db *Database) LoadUser(jid string) (*User, error) {
+	user := NewUser(jid)
+	if err := db.loadRecord(userKey(jid), user); err != nil {
+		return nil, fmt.Errorf("user %s: %w", jid, err)
+	}
+	return user, nil
+}
+
+// SaveUser stores a user, registering it if it's new.
+func (db *Database) SaveUser(user *User) error {
+	if user.JID == "" {
+		return errors.New("user has no JID")
+	}
+	if user.IsNew() {
+		user.Registered = time.Now()
+	}
+	return db.mergeRecord(userKey(user.JID), user)
+}
+
+// LoadGroup returns the record of a group, or the defaults if there is none.
+func (db *Database) LoadGroup(jid string) (*Group, error) {
+	group := NewGroup(jid)
+	if err := db.loadRecord(groupKey(jid), group); err != nil {
+		return nil, fmt.Errorf("group %s: %w", jid, err)
+	}
+	return group, nil
+}
+
+// SaveGroup stores a group, registering it if it's new.
+func (db *Database) SaveGroup(group *Group) error {
+	if group.JID == "" {
+		return errors.New("group has no JID")
+	}
+	if group.IsNew() {
+		group.Registered = time.Now()
+	}
+	return db.mergeRecord(groupKey(group.JID), group)
+}
+
+// loadRecord decodes the object at key over the defaults in v. Fields
+// another plugin stored with a different type keep their default.
+func (db *Database) loadRecord(key string, v interface{}) error {
+	result := db.Get(key)
+	if !result.IsObject() {
+		return nil
+	}
+
+	err := json.Unmarshal([]byte(result.Raw), v)
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return nil
+	}
+	return err
+}
+
+// mergeRecord writes the fields of v into the object at key in one change,
+// keeping the fields v doesn't know about.
+func (db *Database) mergeRecord(key string, v interface{}) error {
+	value, err := normalize(v)
+	if err != nil {
+		return err
+	}
+	fields, _ := value.(map[string]interface{})
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	key = db.prefix + key
+	record, _ := db.get(key).Value().(map[string]interface{})
+	if record == nil {
+		record = make(map[string]interface{})
+	}
+	for name, field := range fields {
+		record[name] = field
+	}
+	return db.set(key, record)
+}
@@ -0,0 +1,40 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestSaveGroupKeepsOtherFields(t *testing.T) {
+	db := openJSON(t, filepath.Join(t.TempDir(), "database.json"))
+	defer db.Close()
+
+	const jid = "120363025246125888@g.us"
+	group, err := db.LoadGroup(jid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A warning added while the group is loaded must survive its save.
+	warning := "moderation_warnings." + EscapeKey("6281234567890")
+	if err := db.SetGroup(jid, warning, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	group.Name = "Yukii"
+	group.Settings["lang"] = "id"
+	if err := db.SaveGroup(group); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := db.GetGroup(jid, warning).Int(); got != 2 {
+		t.Errorf("warnings = %d, want 2", got)
+	}
+	loaded, err := db.LoadGroup(jid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Name != "Yukii" || loaded.Settings["lang"] != "id" || loaded.IsNew() {
+		t.Errorf("loaded %+v", loaded)
+	}
+}
@@ -0,0 +1,250 @@
+package database
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// splitPath splits a gjson path into its keys. ok is false when the path
+// uses more than plain keys, like wildcards, queries, modifiers or array
+// appends; those paths are left to gjson and sjson. objects marks the keys
+// written with sjson's leading ':', which are object keys even when they
+// are numbers.
+func splitPath(path string) (keys []string, objects []bool, ok bool) {
+	var key strings.Builder
+	object := false
+	for i := 0; i < len(path); i++ {
+		switch c := path[i]; c {
+		case '\\':
+			i++
+			if i < len(path) {
+				key.WriteByte(path[i])
+			}
+		case '.':
+			keys = append(keys, key.String())
+			objects = append(objects, object)
+			key.Reset()
+			object = false
+		case '|', '#', '*', '?':
+			return nil, nil, false
+		default:
+			if key.Len() == 0 && c == ':' && !object && i+1 < len(path) && path[i+1] != '.' {
+				object = true
+				continue
+			}
+			if key.Len() == 0 && !object && strings.IndexByte("@!:-", c) >= 0 {
+				return nil, nil, false
+			}
+			key.WriteByte(c)
+		}
+	}
+	keys = append(keys, key.String())
+	objects = append(objects, object)
+
+	for _, key := range keys {
+		if key == "" {
+			return nil, nil, false
+		}
+	}
+	return keys, objects, true
+}
+
+// EscapeKey escapes a single key, like a JID or a phone number, so that it
+// stays one object key in a path: dots and other path syntax are escaped,
+// and numbers get sjson's ':' so they don't turn into array indexes.
+func EscapeKey(key string) string {
+	escaped := gjson.Escape(key)
+	switch {
+	case isIndex(key), strings.HasPrefix(key, "-"):
+		return ":" + escaped
+	case strings.HasPrefix(key, ":"):
+		return "\\" + escaped
+	}
+	return escaped
+}
+
+// gjsonPath drops the ':' of object keys, which only sjson knows. gjson
+// matches numbers against object keys anyway.
+func gjsonPath(path string) string {
+	var b strings.Builder
+	start := true
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		if c == ':' && start {
+			start = false
+			continue
+		}
+		b.WriteByte(c)
+		start = c == '.' || c == '|'
+		if c == '\\' && i+1 < len(path) {
+			i++
+			b.WriteByte(path[i])
+		}
+	}
+	return b.String()
+}
+
+// isIndex reports whether sjson reads a key as an array index.
+func isIndex(key string) bool {
+	if key == "" {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < '0' || key[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// normalize turns a value into what it reads back as from JSON, e.g.
+// structs into maps and integers into float64.
+func normalize(value interface{}) (interface{}, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var result interface{}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// lookup walks plain keys through nested objects. found is false when a
+// key is missing; ok is false when the path crosses an array or scalar,
+// which only gjson can resolve.
+func lookup(node map[string]interface{}, keys []string) (value interface{}, found, ok bool) {
+	for i, key := range keys {
+		value, found = node[key]
+		if !found || i == len(keys)-1 {
+			return value, found, true
+		}
+
+		child, isMap := value.(map[string]interface{})
+		if !isMap {
+			return nil, false, false
+		}
+		node = child
+	}
+	return nil, false, true
+}
+
+// assign sets a value like sjson does for plain keys: missing objects are
+// created and scalars in the way are replaced. ok is false where sjson
+// would index or grow an array instead.
+func assign(node map[string]interface{}, keys []string, objects []bool, value interface{}) bool {
+	for i, key := range keys[:len(keys)-1] {
+		switch child := node[key].(type) {
+		case map[string]interface{}:
+			node = child
+		case []interface{}:
+			return false
+		default:
+			if isIndex(keys[i+1]) && !objects[i+1] {
+				return false
+			}
+			created := make(map[string]interface{})
+			node[key] = created
+			node = created
+		}
+	}
+	node[keys[len(keys)-1]] = value
+	return true
+}
+
+// remove deletes a value by plain keys. ok is false where the path
+// crosses an array.
+func remove(node map[string]interface{}, keys []string) bool {
+	for _, key := range keys[:len(keys)-1] {
+		switch child := node[key].(type) {
+		case map[string]interface{}:
+			node = child
+		case []interface{}:
+			return false
+		default:
+			return true
+		}
+	}
+	delete(node, keys[len(keys)-1])
+	return true
+}
+
+// subtree returns the part of the tree a path can touch: the value of its
+// first key, or the whole tree when even that isn't a plain key.
+func subtree(data map[string]interface{}, path string) (string, map[string]interface{}) {
+	first := path
+	if i := strings.IndexByte(path, '.'); i >= 0 {
+		first = path[:i]
+	}
+	if keys, _, ok := splitPath(first); ok && len(keys) == 1 && !strings.Contains(first, "\\") {
+		part := make(map[string]interface{})
+		if value, exists := data[keys[0]]; exists {
+			part[keys[0]] = value
+		}
+		return keys[0], part
+	}
+	return "", data
+}
+
+// getSlow resolves any gjson path on the smallest part of the tree that
+// holds it.
+func getSlow(data map[string]interface{}, path string) gjson.Result {
+	_, part := subtree(data, path)
+	raw, err := json.Marshal(part)
+	if err != nil {
+		return gjson.Result{}
+	}
+	return gjson.GetBytes(raw, gjsonPath(path))
+}
+
+// editSlow applies sjson to the smallest part of the tree a path touches
+// and returns the key it replaced, or "" when the whole tree changed.
+func editSlow(data map[string]interface{}, path string, edit func([]byte) ([]byte, error)) (string, error) {
+	first, part := subtree(data, path)
+	raw, err := json.Marshal(part)
+	if err != nil {
+		return "", err
+	}
+	raw, err = edit(raw)
+	if err != nil {
+		return "", err
+	}
+
+	var result map[string]interface{}
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return "", err
+	}
+
+	if first == "" {
+		for key := range data {
+			delete(data, key)
+		}
+		for key, value := range result {
+			data[key] = value
+		}
+		return "", nil
+	}
+	if value, exists := result[first]; exists {
+		data[first] = value
+	} else {
+		delete(data, first)
+	}
+	return first, nil
+}
+
+func setSlow(data map[string]interface{}, path string, value interface{}) (string, error) {
+	return editSlow(data, path, func(raw []byte) ([]byte, error) {
+		return sjson.SetBytes(raw, path, value)
+	})
+}
+
+func deleteSlow(data map[string]interface{}, path string) (string, error) {
+	return editSlow(data, path, func(raw []byte) ([]byte, error) {
+		return sjson.DeleteBytes(raw, path)
+	})
+}
@@ -0,0 +1,168 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+var testJIDs = []struct {
+	name string
+	jid  string
+}{
+	{"user", "6281234567890@s.whatsapp.net"},
+	{"device", "6281234567890:12@s.whatsapp.net"},
+	{"lid", "123456789012345@lid"},
+	{"group", "120363025246125888@g.us"},
+	{"legacy group", "6281234567890-1612345678@g.us"},
+	{"number", "6281234567890"},
+	{"negative", "-1"},
+}
+
+func TestEscapeKeyKeepsOneKey(t *testing.T) {
+	for _, tt := range testJIDs {
+		keys, _, ok := splitPath("users." + EscapeKey(tt.jid) + ".points")
+		if !ok || len(keys) != 3 || keys[1] != tt.jid {
+			t.Errorf("%s: split into %q, want [users %s points]", tt.name, keys, tt.jid)
+		}
+	}
+
+	for _, key := range []string{":1", "\\a", "a:b"} {
+		if keys, _, ok := splitPath(EscapeKey(key)); !ok || len(keys) != 1 || keys[0] != key {
+			t.Errorf("%q split into %q, %v", key, keys, ok)
+		}
+	}
+}
+
+func TestNumericKeysStayObjects(t *testing.T) {
+	db := openJSON(t, filepath.Join(t.TempDir(), "database.json"))
+	defer db.Close()
+
+	if err := db.Set("counts."+EscapeKey("6281234567890"), 1); err != nil {
+		t.Fatal(err)
+	}
+	if !db.Get("counts").IsObject() {
+		t.Fatalf("counts = %s, want an object", db.Get("counts").Raw)
+	}
+	if got := db.Get("counts." + EscapeKey("6281234567890")).Int(); got != 1 {
+		t.Errorf("count = %d, want 1", got)
+	}
+	if got := db.Get("counts.6281234567890").Int(); got != 1 {
+		t.Errorf("unescaped read = %d, want 1", got)
+	}
+
+	// Paths that only sjson and gjson can handle understand the ':' too.
+	if err := db.Set("lists."+EscapeKey("42")+".items.-1", "a"); err != nil {
+		t.Fatal(err)
+	}
+	if got := db.Get("lists." + EscapeKey("42") + ".items.#").Int(); got != 1 {
+		t.Errorf("items = %d, want 1", got)
+	}
+	if !db.Get("lists").IsObject() {
+		t.Errorf("lists = %s, want an object", db.Get("lists").Raw)
+	}
+}
+
+func TestJIDKeys(t *testing.T) {
+	for _, driver := range []string{"json", "sqlite"} {
+		t.Run(driver, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "database.json")
+			open := func() *Database {
+				db, err := Open(Options{Driver: driver, Path: path})
+				if err != nil {
+					t.Fatalf("open: %v", err)
+				}
+				return db.Namespace("main")
+			}
+
+			db := open()
+			for _, tt := range testJIDs {
+				if err := db.SetUser(tt.jid, "points", 10); err != nil {
+					t.Fatal(err)
+				}
+				if err := db.SetGroup(tt.jid, "welcome.enabled", true); err != nil {
+					t.Fatal(err)
+				}
+			}
+			db.Close()
+
+			db = open()
+			defer db.Close()
+			users := db.GetMap("users")
+			groups := db.GetMap("groups")
+			for _, tt := range testJIDs {
+				if got := users[tt.jid].Get("points").Int(); got != 10 {
+					t.Errorf("%s: users[%q].points = %d, want 10", tt.name, tt.jid, got)
+				}
+				if got := db.GetUser(tt.jid, "points").Int(); got != 10 {
+					t.Errorf("%s: GetUser = %d, want 10", tt.name, got)
+				}
+				if !groups[tt.jid].Get("welcome.enabled").Bool() {
+					t.Errorf("%s: groups[%q] lost welcome.enabled", tt.name, tt.jid)
+				}
+				if !db.HasUser(tt.jid) || !db.HasGroup(tt.jid) {
+					t.Errorf("%s: record not found", tt.name)
+				}
+			}
+			if len(users) != len(testJIDs) {
+				t.Errorf("%d user records, want %d", len(users), len(testJIDs))
+			}
+
+			jid := testJIDs[0].jid
+			if err := db.DeleteUser(jid, "points"); err != nil {
+				t.Fatal(err)
+			}
+			if db.GetUser(jid, "points").Exists() {
+				t.Errorf("points still set after DeleteUser")
+			}
+		})
+	}
+}
+
+func TestJIDModels(t *testing.T) {
+	db := openJSON(t, filepath.Join(t.TempDir(), "database.json"))
+	defer db.Close()
+
+	for _, tt := range testJIDs {
+		user, err := db.LoadUser(tt.jid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		user.Name = tt.name
+		if err := db.SaveUser(user); err != nil {
+			t.Fatal(err)
+		}
+
+		loaded, err := db.LoadUser(tt.jid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if loaded.IsNew() || loaded.Name != tt.name {
+			t.Errorf("%s: loaded %+v", tt.name, loaded)
+		}
+	}
+}
+
+func TestGetAllReturnsACopy(t *testing.T) {
+	db := openJSON(t, filepath.Join(t.TempDir(), "database.json"))
+	defer db.Close()
+
+	if err := db.Set("global.stats.total", 1); err != nil {
+		t.Fatal(err)
+	}
+	all := db.GetAll()
+	stats := all["global"].(map[string]interface{})["stats"].(map[string]interface{})
+
+	// Writes change the tree in place; the copy must not see them.
+	if err := db.Set("global.stats.total", 2); err != nil {
+		t.Fatal(err)
+	}
+	if stats["total"] != float64(1) {
+		t.Errorf("copy changed to %v", stats["total"])
+	}
+	stats["total"] = 3
+	if got := db.Get("global.stats.total").Int(); got != 2 {
+		t.Errorf("total = %d, want 2", got)
+	}
+}
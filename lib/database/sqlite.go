@@ -0,0 +1,172 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// keySeparator joins the keys of a record path. It sorts before every
+// printable character, so the records under a path form one key range.
+const keySeparator = "\x1f"
+
+// sqliteBackend stores the tree as records in a SQLite key/value table, so
+// a write only touches the records below the changed path. A record is a
+// value two keys deep, like users.<jid> or schedules.<id>, and two keys
+// below an account namespace. Shallower values that aren't objects are
+// records of their own.
+type sqliteBackend struct {
+	db   *sql.DB
+	path string
+}
+
+func newSQLiteBackend(path string) (*sqliteBackend, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?_journal_mode=WAL&_busy_timeout=5000", path))
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec("CREATE TABLE IF NOT EXISTS records (key TEXT PRIMARY KEY, value TEXT NOT NULL)"); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &sqliteBackend{db: db, path: path}, nil
+}
+
+// recordDepth is how many keys of a path name its record.
+func recordDepth(path []string) int {
+	if len(path) > 0 && path[0] == "accounts" {
+		return 4
+	}
+	return 2
+}
+
+func (b *sqliteBackend) empty() (bool, error) {
+	var count int
+	err := b.db.QueryRow("SELECT COUNT(*) FROM records").Scan(&count)
+	return count == 0, err
+}
+
+func (b *sqliteBackend) Load() (map[string]interface{}, error) {
+	data := make(map[string]interface{})
+
+	rows, err := b.db.Query("SELECT key, value FROM records")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key, raw string
+		if err := rows.Scan(&key, &raw); err != nil {
+			return nil, err
+		}
+
+		var value interface{}
+		if err := json.Unmarshal([]byte(raw), &value); err != nil {
+			return nil, fmt.Errorf("record %q: %w", strings.ReplaceAll(key, keySeparator, "."), err)
+		}
+		insert(data, strings.Split(key, keySeparator), value)
+	}
+	return data, rows.Err()
+}
+
+// insert places a record in the tree. Records only ever sit in objects,
+// so unlike assign it never has to deal with arrays.
+func insert(node map[string]interface{}, path []string, value interface{}) {
+	for _, key := range path[:len(path)-1] {
+		child, isMap := node[key].(map[string]interface{})
+		if !isMap {
+			child = make(map[string]interface{})
+			node[key] = child
+		}
+		node = child
+	}
+	node[path[len(path)-1]] = value
+}
+
+func (b *sqliteBackend) Save(data map[string]interface{}, changed [][]string) error {
+	tx, err := b.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, path := range changed {
+		if depth := recordDepth(path); len(path) > depth {
+			path = path[:depth]
+		}
+		if err := b.rewrite(tx, data, path); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// rewrite replaces every record at, under and above path with the current
+// value at path. Records above it can only be values that were replaced
+// by an object.
+func (b *sqliteBackend) rewrite(tx *sql.Tx, data map[string]interface{}, path []string) error {
+	for i := 1; i < len(path); i++ {
+		if _, err := tx.Exec("DELETE FROM records WHERE key = ?", strings.Join(path[:i], keySeparator)); err != nil {
+			return err
+		}
+	}
+
+	if len(path) == 0 {
+		if _, err := tx.Exec("DELETE FROM records"); err != nil {
+			return err
+		}
+		return b.put(tx, nil, data)
+	}
+
+	key := strings.Join(path, keySeparator)
+	// The range holds every key starting with key + separator.
+	if _, err := tx.Exec("DELETE FROM records WHERE key = ? OR (key > ? AND key < ?)",
+		key, key+keySeparator, key+"\x20"); err != nil {
+		return err
+	}
+
+	value, found, ok := lookup(data, path)
+	if !found || !ok {
+		// Keep the object the value was deleted from, even when empty.
+		parent, _, _ := lookup(data, path[:len(path)-1])
+		if object, isMap := parent.(map[string]interface{}); isMap && len(object) == 0 && len(path) > 1 {
+			return b.put(tx, path[:len(path)-1], object)
+		}
+		return nil
+	}
+	return b.put(tx, path, value)
+}
+
+// put stores a value, split into records where it's above record depth.
+func (b *sqliteBackend) put(tx *sql.Tx, path []string, value interface{}) error {
+	if object, isMap := value.(map[string]interface{}); isMap && len(object) > 0 && len(path) < recordDepth(path) {
+		for key, child := range object {
+			if err := b.put(tx, append(path[:len(path):len(path)], key), child); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("INSERT OR REPLACE INTO records (key, value) VALUES (?, ?)",
+		strings.Join(path, keySeparator), string(raw))
+	return err
+}
+
+func (b *sqliteBackend) Close() error {
+	return b.db.Close()
+}
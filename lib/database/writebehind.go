@@ -0,0 +1,114 @@
+package database
+
+import (
+	"strings"
+	"time"
+
+	"yukii-bot/lib/logger"
+)
+
+// defaultFlushThreshold is how many changed paths trigger an early flush
+// in write-behind mode when no threshold is configured.
+const defaultFlushThreshold = 1000
+
+func (s *store) startWriteBehind(interval time.Duration, threshold int) {
+	if threshold <= 0 {
+		threshold = defaultFlushThreshold
+	}
+
+	s.pending = make(map[string][]string)
+	s.flushInterval = interval
+	s.flushThreshold = threshold
+	s.flushNow = make(chan struct{}, 1)
+	s.stop = make(chan struct{})
+	s.stopped = make(chan struct{})
+
+	go s.runWriteBehind()
+}
+
+func (s *store) runWriteBehind() {
+	defer close(s.stopped)
+
+	ticker := time.NewTicker(s.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		case <-s.flushNow:
+		}
+
+		s.mu.Lock()
+		err := s.flush()
+		s.mu.Unlock()
+		if err != nil {
+			logger.Error("Failed to write the database, retrying: %v", err)
+		}
+	}
+}
+
+func (s *store) stopWriteBehind() {
+	if s.stop == nil {
+		return
+	}
+
+	select {
+	case <-s.stop:
+	default:
+		close(s.stop)
+	}
+	<-s.stopped
+}
+
+// markPending records a changed path for the next flush. Repeated changes
+// to a path are written once, and a change of the whole tree covers every
+// other one. The caller holds the lock.
+func (s *store) markPending(changed []string) {
+	if _, all := s.pending[""]; all {
+		return
+	}
+	if len(changed) == 0 {
+		s.pending = map[string][]string{"": nil}
+	} else {
+		s.pending[strings.Join(changed, keySeparator)] = changed
+	}
+
+	if len(s.pending) >= s.flushThreshold {
+		select {
+		case s.flushNow <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// flush writes the pending changes. They stay pending when writing fails.
+// The caller holds the lock.
+func (s *store) flush() error {
+	if len(s.pending) == 0 {
+		return nil
+	}
+
+	changed := make([][]string, 0, len(s.pending))
+	for _, path := range s.pending {
+		changed = append(changed, path)
+	}
+	if err := s.backend.Save(s.data, changed); err != nil {
+		return err
+	}
+
+	for key := range s.pending {
+		delete(s.pending, key)
+	}
+	return nil
+}
+
+// Sync writes pending changes right away, for callers that can't lose a
+// change to a crash. Without write-behind every change is already written.
+func (db *Database) Sync() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	return db.flush()
+}
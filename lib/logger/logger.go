@@ -128,10 +128,22 @@ func getMessageEmoji(messageType string) string {
 		return "📄"
 	case "sticker":
 		return "🌟"
-	case "location":
+	case "location", "live_location":
 		return "📍"
 	case "contact":
 		return "👤"
+	case "contacts":
+		return "👥"
+	case "poll", "poll_vote":
+		return "📊"
+	case "reaction":
+		return "💟"
+	case "edit":
+		return "✏️"
+	case "revoke":
+		return "🗑️"
+	case "button_response", "list_response", "interactive_response":
+		return "🔘"
 	default:
 		return "📱"
 	}
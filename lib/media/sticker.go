@@ -0,0 +1,321 @@
+package media
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"strings"
+
+	xdraw "golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	StickerSize = 512
+
+	MaxStaticStickerBytes   = 100 * 1024
+	MaxAnimatedStickerBytes = 500 * 1024
+
+	maxStickerDuration = 10000
+	minFrameDuration   = 40
+)
+
+var (
+	ErrUnsupportedMedia = errors.New("unsupported media type for sticker")
+	// ErrStickerTooLarge means the media stays over WhatsApp's size limit
+	// even at the smallest size and lowest quality.
+	ErrStickerTooLarge = errors.New("sticker is too large")
+)
+
+// shrinkScales are the sizes the content is drawn at inside the canvas when
+// quantizing alone doesn't get it under the size limit.
+var shrinkScales = []float64{0.75, 0.5, 0.35, 0.25}
+
+const maxReduction = 6
+
+type StickerMetadata struct {
+	PackID    string
+	PackName  string
+	Publisher string
+	Emojis    []string
+}
+
+type Sticker struct {
+	Data     []byte
+	Width    int
+	Height   int
+	Animated bool
+}
+
+// NewSticker converts an image, GIF or video into a 512x512 WebP sticker.
+// The image is fitted inside the canvas with transparent padding, and
+// lossless output is progressively quantized, then drawn smaller, until it
+// fits WhatsApp's size limits. Videos need ffmpeg.
+func NewSticker(data []byte, mimetype string, meta StickerMetadata) (*Sticker, error) {
+	if strings.HasPrefix(mimetype, "video/") {
+		converted, err := videoToGIF(data)
+		if err != nil {
+			return nil, err
+		}
+		data = converted
+	}
+
+	exif, err := StickerExif(meta)
+	if err != nil {
+		return nil, err
+	}
+
+	frames, err := decodeFrames(data)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := MaxStaticStickerBytes
+	if len(frames) > 1 {
+		limit = MaxAnimatedStickerBytes
+	}
+
+	for reduction := 0; reduction <= maxReduction; reduction++ {
+		out, err := encodeSticker(frames, reduction, exif)
+		if err != nil {
+			return nil, err
+		}
+		if len(out) <= limit {
+			return newSticker(out, frames), nil
+		}
+	}
+
+	// Shrinking is only tried with the strongest reduction, each size is a
+	// full encode.
+	for _, scale := range shrinkScales {
+		shrunk := make([]stickerFrame, len(frames))
+		for i, frame := range frames {
+			shrunk[i] = stickerFrame{img: shrink(frame.img, scale), duration: frame.duration}
+		}
+		out, err := encodeSticker(shrunk, maxReduction, exif)
+		if err != nil {
+			return nil, err
+		}
+		if len(out) <= limit {
+			return newSticker(out, frames), nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: over %d KB at every size", ErrStickerTooLarge, limit/1024)
+}
+
+func newSticker(data []byte, frames []stickerFrame) *Sticker {
+	return &Sticker{
+		Data:     data,
+		Width:    StickerSize,
+		Height:   StickerSize,
+		Animated: len(frames) > 1,
+	}
+}
+
+// encodeSticker encodes frames with a reduction from 0 (lossless) to
+// maxReduction. Static images lose color bits; animations lose up to three
+// color bits and then every n-th frame.
+func encodeSticker(frames []stickerFrame, reduction int, exif []byte) ([]byte, error) {
+	quantize := uint(reduction)
+	dropEvery := 0
+	if len(frames) > 1 && quantize > 3 {
+		quantize = 3
+		dropEvery = reduction - 2
+	}
+
+	encoded := make([]Frame, 0, len(frames))
+	for _, frame := range reduceFrames(frames, dropEvery) {
+		encoded = append(encoded, Frame{Pixels: toARGB(frame.img, quantize), Duration: frame.duration})
+	}
+	return EncodeWebP(encoded, StickerSize, StickerSize, exif)
+}
+
+// StickerExif builds the EXIF payload WhatsApp reads sticker pack details from.
+func StickerExif(meta StickerMetadata) ([]byte, error) {
+	if meta.PackID == "" {
+		id := make([]byte, 16)
+		if _, err := rand.Read(id); err != nil {
+			return nil, err
+		}
+		meta.PackID = hex.EncodeToString(id)
+	}
+	if meta.Emojis == nil {
+		meta.Emojis = []string{""}
+	}
+
+	payload, err := json.Marshal(map[string]interface{}{
+		"sticker-pack-id":        meta.PackID,
+		"sticker-pack-name":      meta.PackName,
+		"sticker-pack-publisher": meta.Publisher,
+		"emojis":                 meta.Emojis,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	exif := []byte{
+		0x49, 0x49, 0x2a, 0x00, 0x08, 0x00, 0x00, 0x00,
+		0x01, 0x00, 0x41, 0x57, 0x07, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x16, 0x00, 0x00, 0x00,
+	}
+	binary.LittleEndian.PutUint32(exif[14:18], uint32(len(payload)))
+
+	return append(exif, payload...), nil
+}
+
+type stickerFrame struct {
+	img      *image.RGBA
+	duration int
+}
+
+func decodeFrames(data []byte) ([]stickerFrame, error) {
+	_, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedMedia, err)
+	}
+
+	if format == "gif" {
+		anim, err := gif.DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		if len(anim.Image) > 1 {
+			return decodeGIF(anim), nil
+		}
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	return []stickerFrame{{img: fitToCanvas(img)}}, nil
+}
+
+func decodeGIF(anim *gif.GIF) []stickerFrame {
+	bounds := image.Rect(0, 0, anim.Config.Width, anim.Config.Height)
+	canvas := image.NewRGBA(bounds)
+	var frames []stickerFrame
+	total := 0
+
+	for i, frame := range anim.Image {
+		var previous *image.RGBA
+		disposal := byte(0)
+		if i < len(anim.Disposal) {
+			disposal = anim.Disposal[i]
+		}
+		if disposal == gif.DisposalPrevious {
+			previous = image.NewRGBA(bounds)
+			draw.Draw(previous, bounds, canvas, bounds.Min, draw.Src)
+		}
+
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+
+		delay := 100
+		if i < len(anim.Delay) && anim.Delay[i] > 0 {
+			delay = anim.Delay[i] * 10
+		}
+		delay = max(delay, minFrameDuration)
+
+		frames = append(frames, stickerFrame{img: fitToCanvas(canvas), duration: delay})
+		total += delay
+		if total >= maxStickerDuration {
+			break
+		}
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			draw.Draw(canvas, bounds, previous, bounds.Min, draw.Src)
+		}
+	}
+
+	return frames
+}
+
+// reduceFrames drops every n-th frame, folding its duration into the frame
+// before it so the animation keeps its length.
+func reduceFrames(frames []stickerFrame, n int) []stickerFrame {
+	if n < 2 {
+		return frames
+	}
+
+	var out []stickerFrame
+	for i, frame := range frames {
+		if i%n == n-1 && len(out) > 0 {
+			out[len(out)-1].duration += frame.duration
+			continue
+		}
+		out = append(out, frame)
+	}
+	return out
+}
+
+func fitToCanvas(src image.Image) *image.RGBA {
+	bounds := src.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w >= h {
+		h = max(1, h*StickerSize/w)
+		w = StickerSize
+	} else {
+		w = max(1, w*StickerSize/h)
+		h = StickerSize
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, StickerSize, StickerSize))
+	x := (StickerSize - w) / 2
+	y := (StickerSize - h) / 2
+	xdraw.CatmullRom.Scale(dst, image.Rect(x, y, x+w, y+h), src, bounds, xdraw.Over, nil)
+
+	return dst
+}
+
+// shrink draws a canvas scaled down around its center on a new canvas.
+func shrink(src *image.RGBA, scale float64) *image.RGBA {
+	size := max(1, int(StickerSize*scale))
+	offset := (StickerSize - size) / 2
+
+	dst := image.NewRGBA(image.Rect(0, 0, StickerSize, StickerSize))
+	xdraw.CatmullRom.Scale(dst, image.Rect(offset, offset, offset+size, offset+size), src, src.Bounds(), xdraw.Over, nil)
+
+	return dst
+}
+
+// toARGB converts premultiplied RGBA into the non-premultiplied ARGB layout
+// VP8L expects, dropping the lowest quantize bits of each color channel.
+func toARGB(img *image.RGBA, quantize uint) []uint32 {
+	bounds := img.Bounds()
+	pixels := make([]uint32, 0, bounds.Dx()*bounds.Dy())
+	mask := uint32(0xff) << quantize & 0xff
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		row := img.Pix[(y-bounds.Min.Y)*img.Stride:]
+		for x := 0; x < bounds.Dx(); x++ {
+			r, g, b, a := uint32(row[x*4]), uint32(row[x*4+1]), uint32(row[x*4+2]), uint32(row[x*4+3])
+			if a == 0 {
+				pixels = append(pixels, 0)
+				continue
+			}
+			if a < 0xff {
+				r = min(255, r*0xff/a)
+				g = min(255, g*0xff/a)
+				b = min(255, b*0xff/a)
+			}
+			r, g, b = r&mask, g&mask, b&mask
+			pixels = append(pixels, a<<24|r<<16|g<<8|b)
+		}
+	}
+
+	return pixels
+}
@@ -0,0 +1,95 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/gif"
+	"image/png"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestNoisyImageFitsTheLimit(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	img := image.NewNRGBA(image.Rect(0, 0, StickerSize, StickerSize))
+	rng.Read(img.Pix)
+	for i := 3; i < len(img.Pix); i += 4 {
+		img.Pix[i] = 0xff
+	}
+
+	sticker, err := NewSticker(encodePNG(t, img), "image/png", StickerMetadata{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sticker.Data) > MaxStaticStickerBytes {
+		t.Errorf("sticker is %d bytes, limit is %d", len(sticker.Data), MaxStaticStickerBytes)
+	}
+}
+
+func TestVideoWithoutFFmpeg(t *testing.T) {
+	defer func(path string) { ffmpegPath = path }(ffmpegPath)
+	ffmpegPath = filepath.Join(t.TempDir(), "missing-ffmpeg")
+
+	_, err := NewSticker([]byte("not checked"), "video/mp4", StickerMetadata{})
+	if !errors.Is(err, ErrNoFFmpeg) || !errors.Is(err, ErrUnsupportedMedia) {
+		t.Errorf("err = %v, want %v", err, ErrNoFFmpeg)
+	}
+}
+
+func TestVideoIsAnimated(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake ffmpeg is a shell script")
+	}
+
+	anim := &gif.GIF{}
+	for i := 0; i < 3; i++ {
+		frame := image.NewPaletted(image.Rect(0, 0, 64, 32), palette.Plan9)
+		for j := range frame.Pix {
+			frame.Pix[j] = uint8(i * 40)
+		}
+		frame.Set(i, i, color.White)
+		anim.Image = append(anim.Image, frame)
+		anim.Delay = append(anim.Delay, 10)
+	}
+	dir := t.TempDir()
+	gifPath := filepath.Join(dir, "out.gif")
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, anim); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(gifPath, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The fake ffmpeg prints the GIF when it gets a readable input file.
+	script := "#!/bin/sh\nfor arg; do [ \"$prev\" = -i ] && cat \"$arg\" >/dev/null && cat " + gifPath + "; prev=$arg; done\n"
+	defer func(path string) { ffmpegPath = path }(ffmpegPath)
+	ffmpegPath = filepath.Join(dir, "ffmpeg")
+	if err := os.WriteFile(ffmpegPath, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	sticker, err := NewSticker([]byte("video"), "video/mp4", StickerMetadata{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sticker.Animated {
+		t.Error("sticker from a video isn't animated")
+	}
+}
@@ -0,0 +1,72 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// ErrNoFFmpeg means a video was sent but ffmpeg isn't installed.
+var ErrNoFFmpeg = errors.New("turning videos into stickers needs ffmpeg")
+
+// ffmpegPath is the ffmpeg binary, looked up on the PATH.
+var ffmpegPath = "ffmpeg"
+
+const (
+	videoFPS     = 10
+	videoTimeout = time.Minute
+)
+
+// videoToGIF converts the first seconds of a video into a GIF, which the
+// sticker pipeline already animates. WhatsApp sends GIFs as MP4 videos, so
+// this covers them too.
+func videoToGIF(data []byte) ([]byte, error) {
+	path, err := exec.LookPath(ffmpegPath)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUnsupportedMedia, ErrNoFFmpeg)
+	}
+
+	// MP4 needs a seekable input, so the video goes through a file.
+	input, err := os.CreateTemp("", "yukii-video-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(input.Name())
+	if _, err := input.Write(data); err != nil {
+		input.Close()
+		return nil, err
+	}
+	if err := input.Close(); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), videoTimeout)
+	defer cancel()
+
+	filter := fmt.Sprintf("fps=%d,scale=%d:%d:force_original_aspect_ratio=decrease,"+
+		"split[a][b];[a]palettegen=reserve_transparent=1[p];[b][p]paletteuse",
+		videoFPS, StickerSize, StickerSize)
+	cmd := exec.CommandContext(ctx, path,
+		"-hide_banner", "-loglevel", "error",
+		"-t", fmt.Sprint(maxStickerDuration/1000),
+		"-i", input.Name(),
+		"-an", "-vf", filter,
+		"-f", "gif", "pipe:1",
+	)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return nil, fmt.Errorf("%w: ffmpeg: %s", ErrUnsupportedMedia, message)
+		}
+		return nil, fmt.Errorf("ffmpeg: %w", err)
+	}
+	return stdout.Bytes(), nil
+}
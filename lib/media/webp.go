@@ -0,0 +1,721 @@
+package media
+
+import (
+	"container/heap"
+	"encoding/binary"
+	"errors"
+)
+
+// This file implements a small lossless WebP (VP8L) encoder. It supports the
+// subtract-green and predictor transforms, a color cache and LZ77 backward
+// references, which is enough to produce sticker-sized images without cgo.
+
+const (
+	maxVP8LDimension = 1 << 14
+	maxHuffmanLength = 15
+	maxCodeLenLength = 7
+	numLengthCodes   = 24
+	numDistanceCodes = 40
+	maxCopyLength    = 4096
+	minCopyLength    = 3
+	predictorBits    = 4
+	colorCacheBits   = 8
+	hashBits         = 16
+)
+
+var codeLengthOrder = [19]int{17, 18, 0, 1, 2, 3, 4, 5, 16, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+var predictorModes = []uint32{1, 2, 7}
+
+var errImageTooLarge = errors.New("image dimensions exceed WebP limits")
+
+type bitWriter struct {
+	buf   []byte
+	acc   uint64
+	nbits uint
+}
+
+func (w *bitWriter) writeBits(value uint32, n uint) {
+	if n == 0 {
+		return
+	}
+	w.acc |= uint64(value&(1<<n-1)) << w.nbits
+	w.nbits += n
+	for w.nbits >= 8 {
+		w.buf = append(w.buf, byte(w.acc))
+		w.acc >>= 8
+		w.nbits -= 8
+	}
+}
+
+func (w *bitWriter) bytes() []byte {
+	if w.nbits > 0 {
+		w.buf = append(w.buf, byte(w.acc))
+		w.acc = 0
+		w.nbits = 0
+	}
+	return w.buf
+}
+
+// EncodeVP8L encodes ARGB pixels (0xAARRGGBB, non-premultiplied) into a raw
+// VP8L bitstream, without any RIFF container around it.
+func EncodeVP8L(pixels []uint32, width, height int) ([]byte, error) {
+	if width <= 0 || height <= 0 || width > maxVP8LDimension || height > maxVP8LDimension {
+		return nil, errImageTooLarge
+	}
+	if len(pixels) != width*height {
+		return nil, errors.New("pixel buffer does not match dimensions")
+	}
+
+	alphaUsed := uint32(0)
+	for _, p := range pixels {
+		if p>>24 != 0xff {
+			alphaUsed = 1
+			break
+		}
+	}
+
+	w := &bitWriter{}
+	w.writeBits(0x2f, 8)
+	w.writeBits(uint32(width-1), 14)
+	w.writeBits(uint32(height-1), 14)
+	w.writeBits(alphaUsed, 1)
+	w.writeBits(0, 3)
+
+	argb := make([]uint32, len(pixels))
+	copy(argb, pixels)
+
+	// Subtract green transform.
+	w.writeBits(1, 1)
+	w.writeBits(2, 2)
+	subtractGreen(argb)
+
+	// Predictor transform.
+	w.writeBits(1, 1)
+	w.writeBits(0, 2)
+	w.writeBits(predictorBits-2, 3)
+	modes, modesWidth := applyPredictor(argb, width, height)
+	writeImageData(w, modes, modesWidth, 0, false)
+
+	w.writeBits(0, 1)
+	writeImageData(w, argb, width, colorCacheBits, true)
+
+	return w.bytes(), nil
+}
+
+func subtractGreen(argb []uint32) {
+	for i, p := range argb {
+		g := (p >> 8) & 0xff
+		r := ((p >> 16) - g) & 0xff
+		b := (p - g) & 0xff
+		argb[i] = p&0xff00ff00 | r<<16 | b
+	}
+}
+
+func subPixels(a, b uint32) uint32 {
+	alpha := ((a >> 24) - (b >> 24)) & 0xff
+	red := ((a >> 16) - (b >> 16)) & 0xff
+	green := ((a >> 8) - (b >> 8)) & 0xff
+	blue := (a - b) & 0xff
+	return alpha<<24 | red<<16 | green<<8 | blue
+}
+
+func average2(a, b uint32) uint32 {
+	return (((a ^ b) & 0xfefefefe) >> 1) + (a & b)
+}
+
+func predict(mode uint32, left, top uint32) uint32 {
+	switch mode {
+	case 1:
+		return left
+	case 2:
+		return top
+	case 7:
+		return average2(left, top)
+	}
+	return 0xff000000
+}
+
+func residualCost(residual uint32) uint32 {
+	var cost uint32
+	for shift := 0; shift < 32; shift += 8 {
+		v := int8(residual >> shift)
+		if v < 0 {
+			cost += uint32(-int32(v))
+		} else {
+			cost += uint32(v)
+		}
+	}
+	return cost
+}
+
+// applyPredictor replaces argb with prediction residuals in place and returns
+// the sub-image holding the per-block predictor modes.
+func applyPredictor(argb []uint32, width, height int) ([]uint32, int) {
+	blockSize := 1 << predictorBits
+	tilesX := (width + blockSize - 1) / blockSize
+	tilesY := (height + blockSize - 1) / blockSize
+	modes := make([]uint32, tilesX*tilesY)
+
+	for ty := 0; ty < tilesY; ty++ {
+		for tx := 0; tx < tilesX; tx++ {
+			best, bestCost := predictorModes[0], ^uint32(0)
+			for _, mode := range predictorModes {
+				var cost uint32
+				for y := ty * blockSize; y < height && y < (ty+1)*blockSize; y++ {
+					if y == 0 {
+						continue
+					}
+					for x := tx * blockSize; x < width && x < (tx+1)*blockSize; x++ {
+						if x == 0 {
+							continue
+						}
+						i := y*width + x
+						cost += residualCost(subPixels(argb[i], predict(mode, argb[i-1], argb[i-width])))
+					}
+				}
+				if cost < bestCost {
+					best, bestCost = mode, cost
+				}
+			}
+			modes[ty*tilesX+tx] = 0xff000000 | best<<8
+		}
+	}
+
+	residuals := make([]uint32, len(argb))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			i := y*width + x
+			var pred uint32
+			switch {
+			case x == 0 && y == 0:
+				pred = 0xff000000
+			case y == 0:
+				pred = argb[i-1]
+			case x == 0:
+				pred = argb[i-width]
+			default:
+				mode := (modes[(y>>predictorBits)*tilesX+(x>>predictorBits)] >> 8) & 0xf
+				pred = predict(mode, argb[i-1], argb[i-width])
+			}
+			residuals[i] = subPixels(argb[i], pred)
+		}
+	}
+	copy(argb, residuals)
+
+	return modes, tilesX
+}
+
+const (
+	tokenLiteral = iota
+	tokenCache
+	tokenCopy
+)
+
+type token struct {
+	kind   uint8
+	value  uint32
+	length uint32
+}
+
+func colorCacheIndex(argb uint32, bits uint) uint32 {
+	return (argb * 0x1e35a7bd) >> (32 - bits)
+}
+
+func pixelHash(a, b uint32) uint32 {
+	return ((a * 0x1e35a7bd) ^ (b * 0x9e3779b1)) >> (32 - hashBits) & (1<<hashBits - 1)
+}
+
+func matchLength(argb []uint32, pos, dist int) int {
+	if dist <= 0 || dist > pos {
+		return 0
+	}
+	n := 0
+	for pos+n < len(argb) && n < maxCopyLength && argb[pos+n] == argb[pos+n-dist] {
+		n++
+	}
+	return n
+}
+
+func tokenize(argb []uint32, width int, cacheBits uint) []token {
+	tokens := make([]token, 0, len(argb)/2)
+	var cache []uint32
+	if cacheBits > 0 {
+		cache = make([]uint32, 1<<cacheBits)
+	}
+	hashTable := make([]int32, 1<<hashBits)
+	for i := range hashTable {
+		hashTable[i] = -1
+	}
+
+	insertHash := func(pos int) {
+		if pos+1 < len(argb) {
+			hashTable[pixelHash(argb[pos], argb[pos+1])] = int32(pos)
+		}
+	}
+
+	for i := 0; i < len(argb); {
+		bestLen, bestDist := 0, 0
+		for _, dist := range []int{1, width} {
+			if n := matchLength(argb, i, dist); n > bestLen {
+				bestLen, bestDist = n, dist
+			}
+		}
+		if i+1 < len(argb) {
+			if cand := int(hashTable[pixelHash(argb[i], argb[i+1])]); cand >= 0 {
+				if n := matchLength(argb, i, i-cand); n > bestLen+1 {
+					bestLen, bestDist = n, i-cand
+				}
+			}
+		}
+
+		if bestLen >= minCopyLength {
+			tokens = append(tokens, token{kind: tokenCopy, value: uint32(bestDist), length: uint32(bestLen)})
+			for k := 0; k < bestLen; k++ {
+				if cache != nil {
+					cache[colorCacheIndex(argb[i+k], cacheBits)] = argb[i+k]
+				}
+				insertHash(i + k)
+			}
+			i += bestLen
+			continue
+		}
+
+		p := argb[i]
+		if cache != nil {
+			idx := colorCacheIndex(p, cacheBits)
+			if cache[idx] == p {
+				tokens = append(tokens, token{kind: tokenCache, value: idx})
+			} else {
+				tokens = append(tokens, token{kind: tokenLiteral, value: p})
+				cache[idx] = p
+			}
+		} else {
+			tokens = append(tokens, token{kind: tokenLiteral, value: p})
+		}
+		insertHash(i)
+		i++
+	}
+
+	return tokens
+}
+
+// prefixEncode splits a length or distance value into its prefix symbol and
+// the extra bits that follow it.
+func prefixEncode(value uint32) (symbol, extraBits, extra uint32) {
+	v := value - 1
+	if v < 4 {
+		return v, 0, 0
+	}
+	highest := uint32(31)
+	for v>>highest == 0 {
+		highest--
+	}
+	second := (v >> (highest - 1)) & 1
+	extraBits = highest - 1
+	return 2*highest + second, extraBits, v & (1<<extraBits - 1)
+}
+
+func distanceCode(dist, width int) uint32 {
+	switch dist {
+	case width:
+		return 1
+	case 1:
+		return 2
+	}
+	return uint32(dist + 120)
+}
+
+func writeImageData(w *bitWriter, argb []uint32, width int, cacheBits uint, isMain bool) {
+	if cacheBits > 0 {
+		w.writeBits(1, 1)
+		w.writeBits(uint32(cacheBits), 4)
+	} else {
+		w.writeBits(0, 1)
+	}
+	if isMain {
+		w.writeBits(0, 1)
+	}
+
+	tokens := tokenize(argb, width, cacheBits)
+
+	cacheSize := 0
+	if cacheBits > 0 {
+		cacheSize = 1 << cacheBits
+	}
+	green := make([]uint32, 256+numLengthCodes+cacheSize)
+	red := make([]uint32, 256)
+	blue := make([]uint32, 256)
+	alpha := make([]uint32, 256)
+	dist := make([]uint32, numDistanceCodes)
+
+	for _, t := range tokens {
+		switch t.kind {
+		case tokenLiteral:
+			alpha[t.value>>24]++
+			red[(t.value>>16)&0xff]++
+			green[(t.value>>8)&0xff]++
+			blue[t.value&0xff]++
+		case tokenCache:
+			green[256+numLengthCodes+int(t.value)]++
+		case tokenCopy:
+			lenSym, _, _ := prefixEncode(t.length)
+			green[256+lenSym]++
+			distSym, _, _ := prefixEncode(distanceCode(int(t.value), width))
+			dist[distSym]++
+		}
+	}
+
+	greenCode := writePrefixCode(w, green)
+	redCode := writePrefixCode(w, red)
+	blueCode := writePrefixCode(w, blue)
+	alphaCode := writePrefixCode(w, alpha)
+	distCode := writePrefixCode(w, dist)
+
+	for _, t := range tokens {
+		switch t.kind {
+		case tokenLiteral:
+			greenCode.write(w, int((t.value>>8)&0xff))
+			redCode.write(w, int((t.value>>16)&0xff))
+			blueCode.write(w, int(t.value&0xff))
+			alphaCode.write(w, int(t.value>>24))
+		case tokenCache:
+			greenCode.write(w, 256+numLengthCodes+int(t.value))
+		case tokenCopy:
+			sym, n, extra := prefixEncode(t.length)
+			greenCode.write(w, 256+int(sym))
+			w.writeBits(extra, uint(n))
+			sym, n, extra = prefixEncode(distanceCode(int(t.value), width))
+			distCode.write(w, int(sym))
+			w.writeBits(extra, uint(n))
+		}
+	}
+}
+
+type prefixCode struct {
+	lengths []uint8
+	codes   []uint16
+	single  bool
+}
+
+func (c *prefixCode) write(w *bitWriter, symbol int) {
+	if c.single {
+		return
+	}
+	w.writeBits(uint32(c.codes[symbol]), uint(c.lengths[symbol]))
+}
+
+func writePrefixCode(w *bitWriter, counts []uint32) *prefixCode {
+	var used []int
+	for sym, n := range counts {
+		if n > 0 {
+			used = append(used, sym)
+		}
+	}
+
+	if len(used) <= 2 && (len(used) == 0 || used[len(used)-1] < 256) {
+		if len(used) == 0 {
+			used = []int{0}
+		}
+		w.writeBits(1, 1)
+		w.writeBits(uint32(len(used)-1), 1)
+		if used[0] < 2 {
+			w.writeBits(0, 1)
+			w.writeBits(uint32(used[0]), 1)
+		} else {
+			w.writeBits(1, 1)
+			w.writeBits(uint32(used[0]), 8)
+		}
+		if len(used) == 1 {
+			return &prefixCode{single: true}
+		}
+		w.writeBits(uint32(used[1]), 8)
+		lengths := make([]uint8, len(counts))
+		lengths[used[0]], lengths[used[1]] = 1, 1
+		return &prefixCode{lengths: lengths, codes: canonicalCodes(lengths)}
+	}
+
+	lengths := huffmanLengths(counts, maxHuffmanLength)
+	w.writeBits(0, 1)
+	writeCodeLengths(w, lengths)
+
+	return &prefixCode{lengths: lengths, codes: canonicalCodes(lengths)}
+}
+
+type codeLengthToken struct {
+	symbol    uint8
+	extra     uint32
+	extraBits uint
+}
+
+func writeCodeLengths(w *bitWriter, lengths []uint8) {
+	var tokens []codeLengthToken
+	for i := 0; i < len(lengths); {
+		l := lengths[i]
+		run := 1
+		for i+run < len(lengths) && lengths[i+run] == l {
+			run++
+		}
+		i += run
+
+		if l == 0 {
+			for run >= 11 {
+				n := min(run, 138)
+				tokens = append(tokens, codeLengthToken{18, uint32(n - 11), 7})
+				run -= n
+			}
+			if run >= 3 {
+				tokens = append(tokens, codeLengthToken{17, uint32(run - 3), 3})
+				run = 0
+			}
+			for ; run > 0; run-- {
+				tokens = append(tokens, codeLengthToken{symbol: 0})
+			}
+			continue
+		}
+
+		tokens = append(tokens, codeLengthToken{symbol: l})
+		run--
+		for run >= 3 {
+			n := min(run, 6)
+			tokens = append(tokens, codeLengthToken{16, uint32(n - 3), 2})
+			run -= n
+		}
+		for ; run > 0; run-- {
+			tokens = append(tokens, codeLengthToken{symbol: l})
+		}
+	}
+
+	counts := make([]uint32, len(codeLengthOrder))
+	for _, t := range tokens {
+		counts[t.symbol]++
+	}
+	clLengths := huffmanLengths(counts, maxCodeLenLength)
+	clCode := &prefixCode{lengths: clLengths, codes: canonicalCodes(clLengths)}
+	used := 0
+	for _, n := range counts {
+		if n > 0 {
+			used++
+		}
+	}
+	clCode.single = used == 1
+
+	n := len(codeLengthOrder)
+	for n > 4 && clLengths[codeLengthOrder[n-1]] == 0 {
+		n--
+	}
+	w.writeBits(uint32(n-4), 4)
+	for _, sym := range codeLengthOrder[:n] {
+		w.writeBits(uint32(clLengths[sym]), 3)
+	}
+
+	// Code lengths are written for the whole alphabet.
+	w.writeBits(0, 1)
+	for _, t := range tokens {
+		clCode.write(w, int(t.symbol))
+		w.writeBits(t.extra, t.extraBits)
+	}
+}
+
+type huffmanNode struct {
+	weight      uint64
+	symbol      int
+	left, right int
+}
+
+type huffmanHeap struct {
+	nodes []huffmanNode
+	items []int
+}
+
+func (h *huffmanHeap) Len() int { return len(h.items) }
+func (h *huffmanHeap) Less(i, j int) bool {
+	a, b := h.nodes[h.items[i]], h.nodes[h.items[j]]
+	if a.weight != b.weight {
+		return a.weight < b.weight
+	}
+	return h.items[i] < h.items[j]
+}
+func (h *huffmanHeap) Swap(i, j int) { h.items[i], h.items[j] = h.items[j], h.items[i] }
+func (h *huffmanHeap) Push(x any)    { h.items = append(h.items, x.(int)) }
+func (h *huffmanHeap) Pop() any {
+	n := len(h.items)
+	item := h.items[n-1]
+	h.items = h.items[:n-1]
+	return item
+}
+
+// huffmanLengths builds code lengths no longer than maxLength. Rare symbols
+// are flattened towards a rising floor until the tree fits.
+func huffmanLengths(counts []uint32, maxLength int) []uint8 {
+	lengths := make([]uint8, len(counts))
+	var used []int
+	for sym, n := range counts {
+		if n > 0 {
+			used = append(used, sym)
+		}
+	}
+	switch len(used) {
+	case 0:
+		return lengths
+	case 1:
+		lengths[used[0]] = 1
+		return lengths
+	}
+
+	for floor := uint64(1); ; floor *= 2 {
+		h := &huffmanHeap{}
+		for _, sym := range used {
+			h.nodes = append(h.nodes, huffmanNode{weight: max(uint64(counts[sym]), floor), symbol: sym, left: -1, right: -1})
+			h.items = append(h.items, len(h.nodes)-1)
+		}
+		heap.Init(h)
+		for h.Len() > 1 {
+			a := heap.Pop(h).(int)
+			b := heap.Pop(h).(int)
+			h.nodes = append(h.nodes, huffmanNode{weight: h.nodes[a].weight + h.nodes[b].weight, symbol: -1, left: a, right: b})
+			heap.Push(h, len(h.nodes)-1)
+		}
+
+		depth := 0
+		var walk func(node, d int)
+		walk = func(node, d int) {
+			n := h.nodes[node]
+			if n.symbol >= 0 {
+				lengths[n.symbol] = uint8(d)
+				depth = max(depth, d)
+				return
+			}
+			walk(n.left, d+1)
+			walk(n.right, d+1)
+		}
+		walk(h.items[0], 0)
+
+		if depth <= maxLength {
+			return lengths
+		}
+	}
+}
+
+func canonicalCodes(lengths []uint8) []uint16 {
+	var count [maxHuffmanLength + 1]int
+	for _, l := range lengths {
+		if l > 0 {
+			count[l]++
+		}
+	}
+
+	var next [maxHuffmanLength + 1]int
+	code := 0
+	for bits := 1; bits <= maxHuffmanLength; bits++ {
+		code = (code + count[bits-1]) << 1
+		next[bits] = code
+	}
+
+	codes := make([]uint16, len(lengths))
+	for sym, l := range lengths {
+		if l == 0 {
+			continue
+		}
+		codes[sym] = reverseBits(uint16(next[l]), l)
+		next[l]++
+	}
+	return codes
+}
+
+func reverseBits(code uint16, length uint8) uint16 {
+	var out uint16
+	for i := uint8(0); i < length; i++ {
+		out = out<<1 | code&1
+		code >>= 1
+	}
+	return out
+}
+
+func appendChunk(dst []byte, fourCC string, payload []byte) []byte {
+	dst = append(dst, fourCC...)
+	dst = binary.LittleEndian.AppendUint32(dst, uint32(len(payload)))
+	dst = append(dst, payload...)
+	if len(payload)%2 == 1 {
+		dst = append(dst, 0)
+	}
+	return dst
+}
+
+func appendUint24(dst []byte, v int) []byte {
+	return append(dst, byte(v), byte(v>>8), byte(v>>16))
+}
+
+// Frame is a single image of an animated WebP.
+type Frame struct {
+	Pixels   []uint32
+	Duration int
+}
+
+// EncodeWebP wraps one or more VP8L frames into an extended WebP container
+// with optional EXIF metadata. More than one frame produces an animation.
+func EncodeWebP(frames []Frame, width, height int, exif []byte) ([]byte, error) {
+	if len(frames) == 0 {
+		return nil, errors.New("no frames to encode")
+	}
+
+	animated := len(frames) > 1
+	var flags byte
+	var body []byte
+
+	for _, frame := range frames {
+		for _, p := range frame.Pixels {
+			if p>>24 != 0xff {
+				flags |= 0x10
+				break
+			}
+		}
+	}
+	if len(exif) > 0 {
+		flags |= 0x08
+	}
+
+	if animated {
+		flags |= 0x02
+		body = appendChunk(body, "ANIM", []byte{0, 0, 0, 0, 0, 0})
+		for _, frame := range frames {
+			bitstream, err := EncodeVP8L(frame.Pixels, width, height)
+			if err != nil {
+				return nil, err
+			}
+			var anmf []byte
+			anmf = appendUint24(anmf, 0)
+			anmf = appendUint24(anmf, 0)
+			anmf = appendUint24(anmf, width-1)
+			anmf = appendUint24(anmf, height-1)
+			anmf = appendUint24(anmf, frame.Duration)
+			anmf = append(anmf, 0x02)
+			anmf = appendChunk(anmf, "VP8L", bitstream)
+			body = appendChunk(body, "ANMF", anmf)
+		}
+	} else {
+		bitstream, err := EncodeVP8L(frames[0].Pixels, width, height)
+		if err != nil {
+			return nil, err
+		}
+		body = appendChunk(body, "VP8L", bitstream)
+	}
+
+	if len(exif) > 0 {
+		body = appendChunk(body, "EXIF", exif)
+	}
+
+	vp8x := []byte{flags, 0, 0, 0}
+	vp8x = appendUint24(vp8x, width-1)
+	vp8x = appendUint24(vp8x, height-1)
+
+	var out []byte
+	out = append(out, "RIFF"...)
+	out = binary.LittleEndian.AppendUint32(out, 0)
+	out = append(out, "WEBP"...)
+	out = appendChunk(out, "VP8X", vp8x)
+	out = append(out, body...)
+	binary.LittleEndian.PutUint32(out[4:8], uint32(len(out)-8))
+
+	return out, nil
+}
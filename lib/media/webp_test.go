@@ -0,0 +1,250 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"math/rand"
+	"testing"
+
+	"golang.org/x/image/vp8l"
+	"golang.org/x/image/webp"
+)
+
+// decodeVP8L decodes a raw VP8L bitstream back into ARGB pixels.
+func decodeVP8L(t *testing.T, data []byte) ([]uint32, int, int) {
+	t.Helper()
+
+	img, err := vp8l.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	return nrgbaToARGB(img), img.Bounds().Dx(), img.Bounds().Dy()
+}
+
+func nrgbaToARGB(img image.Image) []uint32 {
+	nrgba := img.(*image.NRGBA)
+	bounds := nrgba.Bounds()
+	pixels := make([]uint32, 0, bounds.Dx()*bounds.Dy())
+	for y := 0; y < bounds.Dy(); y++ {
+		row := nrgba.Pix[y*nrgba.Stride:]
+		for x := 0; x < bounds.Dx(); x++ {
+			r, g, b, a := uint32(row[x*4]), uint32(row[x*4+1]), uint32(row[x*4+2]), uint32(row[x*4+3])
+			pixels = append(pixels, a<<24|r<<16|g<<8|b)
+		}
+	}
+	return pixels
+}
+
+func testImages() map[string]struct {
+	pixels        []uint32
+	width, height int
+} {
+	rng := rand.New(rand.NewSource(1))
+	images := make(map[string]struct {
+		pixels        []uint32
+		width, height int
+	})
+	add := func(name string, width, height int, pixel func(x, y int) uint32) {
+		pixels := make([]uint32, width*height)
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				pixels[y*width+x] = pixel(x, y)
+			}
+		}
+		images[name] = struct {
+			pixels        []uint32
+			width, height int
+		}{pixels, width, height}
+	}
+
+	add("single pixel", 1, 1, func(x, y int) uint32 { return 0xff336699 })
+	add("solid", 64, 64, func(x, y int) uint32 { return 0xffff0000 })
+	add("transparent", 33, 17, func(x, y int) uint32 { return 0 })
+	add("odd size gradient", 37, 23, func(x, y int) uint32 {
+		return 0xff000000 | uint32(x*7)<<16 | uint32(y*11)<<8 | uint32(x+y)
+	})
+	add("alpha gradient", 128, 64, func(x, y int) uint32 {
+		return uint32(x*2)<<24 | uint32(y*4)<<16 | 0x80<<8 | uint32(x)
+	})
+	add("repeated pattern", 200, 100, func(x, y int) uint32 {
+		palette := []uint32{0xff112233, 0xff445566, 0x80778899, 0xffaabbcc, 0xffddeeff}
+		return palette[(x/3+y/5)%len(palette)]
+	})
+	add("few colors noise", 150, 150, func(x, y int) uint32 {
+		return []uint32{0xff000000, 0xffffffff, 0xff00ff00}[rng.Intn(3)]
+	})
+	add("noise", 512, 512, func(x, y int) uint32 { return rng.Uint32() | 0xff000000 })
+	add("noise with alpha", 97, 61, func(x, y int) uint32 { return rng.Uint32() })
+	return images
+}
+
+func TestVP8LRoundTrip(t *testing.T) {
+	for name, img := range testImages() {
+		t.Run(name, func(t *testing.T) {
+			// The encoder transforms its input in place on some paths.
+			want := append([]uint32(nil), img.pixels...)
+
+			data, err := EncodeVP8L(img.pixels, img.width, img.height)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, width, height := decodeVP8L(t, data)
+			if width != img.width || height != img.height {
+				t.Fatalf("size %dx%d, want %dx%d", width, height, img.width, img.height)
+			}
+			checkPixels(t, got, want)
+		})
+	}
+}
+
+func TestVP8LRejectsBadInput(t *testing.T) {
+	if _, err := EncodeVP8L(make([]uint32, 4), 3, 1); err == nil {
+		t.Error("pixel buffer of the wrong size was accepted")
+	}
+	if _, err := EncodeVP8L(nil, 0, 0); err == nil {
+		t.Error("empty image was accepted")
+	}
+	if _, err := EncodeVP8L(nil, maxVP8LDimension+1, 1); err == nil {
+		t.Error("oversized image was accepted")
+	}
+}
+
+type chunk struct {
+	id      string
+	payload []byte
+}
+
+// readChunks splits a RIFF payload into its chunks. The x/image decoder
+// refuses VP8L after a VP8X chunk with the alpha flag, which other decoders
+// accept, so the tests walk the container themselves.
+func readChunks(t *testing.T, data []byte) []chunk {
+	t.Helper()
+
+	var chunks []chunk
+	for len(data) > 0 {
+		if len(data) < 8 {
+			t.Fatalf("truncated chunk header")
+		}
+		size := int(binary.LittleEndian.Uint32(data[4:8]))
+		if len(data) < 8+size {
+			t.Fatalf("chunk %q is truncated", data[:4])
+		}
+		chunks = append(chunks, chunk{string(data[:4]), data[8 : 8+size]})
+		data = data[8+size+size%2:]
+	}
+	return chunks
+}
+
+func checkPixels(t *testing.T, got, want []uint32) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Fatalf("%d pixels, want %d", len(got), len(want))
+	}
+	for i := range want {
+		// Fully transparent pixels may keep any color.
+		if got[i] != want[i] && !(want[i]>>24 == 0 && got[i]>>24 == 0) {
+			t.Fatalf("pixel %d = %08x, want %08x", i, got[i], want[i])
+		}
+	}
+}
+
+func TestEncodeWebPStatic(t *testing.T) {
+	img := testImages()["alpha gradient"]
+	want := append([]uint32(nil), img.pixels...)
+
+	exif, err := StickerExif(StickerMetadata{PackName: "Yukii", Publisher: "Bot"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := EncodeWebP([]Frame{{Pixels: img.pixels}}, img.width, img.height, exif)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		t.Fatalf("not a WebP file: %q", data[:12])
+	}
+	if size := int(binary.LittleEndian.Uint32(data[4:8])); size != len(data)-8 {
+		t.Fatalf("RIFF size = %d, want %d", size, len(data)-8)
+	}
+	chunks := readChunks(t, data[12:])
+	if len(chunks) != 3 || chunks[0].id != "VP8X" || chunks[1].id != "VP8L" || chunks[2].id != "EXIF" {
+		t.Fatalf("chunks = %v, want VP8X VP8L EXIF", chunkIDs(chunks))
+	}
+	if flags := chunks[0].payload[0]; flags != 0x10|0x08 {
+		t.Errorf("VP8X flags = %#x, want alpha and EXIF", flags)
+	}
+	if !bytes.Contains(chunks[2].payload, []byte(`"sticker-pack-name":"Yukii"`)) {
+		t.Error("EXIF metadata is missing")
+	}
+
+	got, width, height := decodeVP8L(t, chunks[1].payload)
+	if width != img.width || height != img.height {
+		t.Fatalf("size = %dx%d, want %dx%d", width, height, img.width, img.height)
+	}
+	checkPixels(t, got, want)
+}
+
+func TestEncodeWebPOpaque(t *testing.T) {
+	img := testImages()["solid"]
+	data, err := EncodeWebP([]Frame{{Pixels: img.pixels}}, img.width, img.height, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Without alpha the standard decoder reads the file as well.
+	decoded, err := webp.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	checkPixels(t, nrgbaToARGB(decoded), img.pixels)
+}
+
+func TestEncodeWebPAnimated(t *testing.T) {
+	img := testImages()["alpha gradient"]
+	reversed := make([]uint32, len(img.pixels))
+	for i, p := range img.pixels {
+		reversed[len(reversed)-1-i] = p
+	}
+	want := [][]uint32{img.pixels, reversed}
+	frames := []Frame{
+		{Pixels: append([]uint32(nil), want[0]...), Duration: 80},
+		{Pixels: append([]uint32(nil), want[1]...), Duration: 120},
+	}
+	width, height := img.width, img.height
+
+	data, err := EncodeWebP(frames, width, height, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunks := readChunks(t, data[12:])
+	if len(chunks) != 4 || chunks[1].id != "ANIM" || chunks[2].id != "ANMF" || chunks[3].id != "ANMF" {
+		t.Fatalf("chunks = %v, want VP8X ANIM ANMF ANMF", chunkIDs(chunks))
+	}
+	if flags := chunks[0].payload[0]; flags&0x02 == 0 {
+		t.Errorf("VP8X flags = %#x, animation bit not set", flags)
+	}
+
+	for i, anmf := range chunks[2:] {
+		header := anmf.payload[:16]
+		if duration := int(header[12]) | int(header[13])<<8 | int(header[14])<<16; duration != frames[i].Duration {
+			t.Errorf("frame %d lasts %d ms, want %d", i, duration, frames[i].Duration)
+		}
+		inner := readChunks(t, anmf.payload[16:])
+		if len(inner) != 1 || inner[0].id != "VP8L" {
+			t.Fatalf("frame %d chunks = %v, want VP8L", i, chunkIDs(inner))
+		}
+		got, _, _ := decodeVP8L(t, inner[0].payload)
+		checkPixels(t, got, want[i])
+	}
+}
+
+func chunkIDs(chunks []chunk) []string {
+	ids := make([]string, len(chunks))
+	for i, c := range chunks {
+		ids[i] = c.id
+	}
+	return ids
+}
@@ -0,0 +1,201 @@
+package moderation
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"yukii-bot/lib/database"
+)
+
+const (
+	ActionDelete = "delete"
+	ActionWarn   = "warn"
+	ActionKick   = "kick"
+)
+
+const (
+	ViolationLink  = "invite link"
+	ViolationFlood = "flooding"
+	ViolationWord  = "banned word"
+)
+
+var inviteLinkRegex = regexp.MustCompile(`(?i)chat\.whatsapp\.com/(?:invite/)?[0-9A-Za-z]{10,}`)
+
+type Settings struct {
+	AntiLink    bool
+	AntiFlood   bool
+	AntiWords   bool
+	Words       []string
+	FloodLimit  int
+	FloodWindow time.Duration
+	Action      string
+	MaxWarnings int
+}
+
+func DefaultSettings() Settings {
+	return Settings{
+		FloodLimit:  5,
+		FloodWindow: 10 * time.Second,
+		Action:      ActionWarn,
+		MaxWarnings: 3,
+	}
+}
+
+func (s Settings) Enabled() bool {
+	return s.AntiLink || s.AntiFlood || (s.AntiWords && len(s.Words) > 0)
+}
+
+// LoadSettings reads the moderation settings of a group, falling back to
+// the defaults for anything that was never configured.
+func LoadSettings(db *database.Database, group string) Settings {
+	settings := DefaultSettings()
+	stored := db.GetGroup(group, "moderation")
+	if !stored.Exists() {
+		return settings
+	}
+
+	settings.AntiLink = stored.Get("antilink").Bool()
+	settings.AntiFlood = stored.Get("antiflood").Bool()
+	settings.AntiWords = stored.Get("antiwords").Bool()
+	for _, word := range stored.Get("words").Array() {
+		settings.Words = append(settings.Words, word.String())
+	}
+	if limit := stored.Get("flood_limit").Int(); limit > 0 {
+		settings.FloodLimit = int(limit)
+	}
+	if window := stored.Get("flood_window").Int(); window > 0 {
+		settings.FloodWindow = time.Duration(window) * time.Second
+	}
+	if action := stored.Get("action").String(); action != "" {
+		settings.Action = action
+	}
+	if maxWarnings := stored.Get("max_warnings"); maxWarnings.Exists() {
+		settings.MaxWarnings = int(maxWarnings.Int())
+	}
+
+	return settings
+}
+
+func SaveSettings(db *database.Database, group string, settings Settings) error {
+	words := settings.Words
+	if words == nil {
+		words = []string{}
+	}
+
+	return db.SetGroup(group, "moderation", map[string]interface{}{
+		"antilink":     settings.AntiLink,
+		"antiflood":    settings.AntiFlood,
+		"antiwords":    settings.AntiWords,
+		"words":        words,
+		"flood_limit":  settings.FloodLimit,
+		"flood_window": int(settings.FloodWindow / time.Second),
+		"action":       settings.Action,
+		"max_warnings": settings.MaxWarnings,
+	})
+}
+
+func ContainsInviteLink(text string) bool {
+	return inviteLinkRegex.MatchString(text)
+}
+
+// FindBannedWord returns the first banned word that appears in text as a
+// whole word, ignoring case.
+func FindBannedWord(text string, words []string) (string, bool) {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r > 127)
+	})
+	present := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		present[field] = true
+	}
+
+	lower := strings.ToLower(text)
+	for _, word := range words {
+		word = strings.ToLower(strings.TrimSpace(word))
+		if word == "" {
+			continue
+		}
+		if strings.Contains(word, " ") {
+			if strings.Contains(lower, word) {
+				return word, true
+			}
+		} else if present[word] {
+			return word, true
+		}
+	}
+	return "", false
+}
+
+// FloodTracker counts messages per key in a sliding window.
+type FloodTracker struct {
+	hits map[string][]time.Time
+	mu   sync.Mutex
+}
+
+func NewFloodTracker() *FloodTracker {
+	return &FloodTracker{
+		hits: make(map[string][]time.Time),
+	}
+}
+
+// Hit records a message for key and reports whether more than limit
+// messages arrived within window.
+func (t *FloodTracker) Hit(key string, limit int, window time.Duration) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	cutoff := now.Add(-window)
+
+	recent := t.hits[key][:0]
+	for _, hit := range t.hits[key] {
+		if hit.After(cutoff) {
+			recent = append(recent, hit)
+		}
+	}
+	recent = append(recent, now)
+	t.hits[key] = recent
+
+	return len(recent) > limit
+}
+
+func (t *FloodTracker) Reset(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.hits, key)
+}
+
+// warningsMu serializes warning updates, which read and then write the
+// count.
+var warningsMu sync.Mutex
+
+// warningKey escapes the user, a JID or a plain phone number that would
+// otherwise index an array.
+func warningKey(user string) string {
+	return "moderation_warnings." + database.EscapeKey(user)
+}
+
+func GetWarnings(db *database.Database, group, user string) int {
+	return int(db.GetGroup(group, warningKey(user)).Int())
+}
+
+func AddWarning(db *database.Database, group, user string) (int, error) {
+	warningsMu.Lock()
+	defer warningsMu.Unlock()
+
+	count := GetWarnings(db, group, user) + 1
+	if err := db.SetGroup(group, warningKey(user), count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func ResetWarnings(db *database.Database, group, user string) error {
+	warningsMu.Lock()
+	defer warningsMu.Unlock()
+
+	return db.DeleteGroup(group, warningKey(user))
+}
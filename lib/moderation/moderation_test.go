@@ -0,0 +1,70 @@
+package moderation
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"yukii-bot/lib/database"
+)
+
+func TestWarnNumericUserInNewGroup(t *testing.T) {
+	db, err := database.Init(filepath.Join(t.TempDir(), "database.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	const group = "120363025246125888@g.us"
+	const user = "6281234567890"
+
+	count, err := AddWarning(db, group, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("count = %d, want 1", count)
+	}
+
+	warnings := db.GetGroup(group, "moderation_warnings")
+	if !warnings.IsObject() {
+		t.Fatalf("moderation_warnings = %.50s, want an object", warnings.Raw)
+	}
+	if got := GetWarnings(db, group, user); got != 1 {
+		t.Errorf("GetWarnings = %d, want 1", got)
+	}
+
+	if err := ResetWarnings(db, group, user); err != nil {
+		t.Fatal(err)
+	}
+	if got := GetWarnings(db, group, user); got != 0 {
+		t.Errorf("GetWarnings after reset = %d, want 0", got)
+	}
+}
+
+func TestConcurrentWarnings(t *testing.T) {
+	db, err := database.Init(filepath.Join(t.TempDir(), "database.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	const group = "120363025246125888@g.us"
+	const user = "6281234567890"
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := AddWarning(db, group, user); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := GetWarnings(db, group, user); got != 20 {
+		t.Errorf("GetWarnings = %d, want 20", got)
+	}
+}
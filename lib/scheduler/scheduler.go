@@ -0,0 +1,279 @@
+package scheduler
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"yukii-bot/lib/database"
+	"yukii-bot/lib/logger"
+
+	"github.com/robfig/cron/v3"
+)
+
+// Kinds of jobs.
+const (
+	// KindMessage sends the text as is.
+	KindMessage = "message"
+	// KindCommand runs the text as a command on behalf of the creator.
+	KindCommand = "command"
+)
+
+// Job is a scheduled message or command. Jobs saved before kinds existed
+// have an empty Kind.
+type Job struct {
+	ID       string    `json:"id"`
+	Kind     string    `json:"kind,omitempty"`
+	Chat     string    `json:"chat"`
+	Creator  string    `json:"creator"`
+	Text     string    `json:"text"`
+	Mentions []string  `json:"mentions,omitempty"`
+	Cron     string    `json:"cron,omitempty"`
+	RunAt    time.Time `json:"run_at"`
+	Created  time.Time `json:"created"`
+}
+
+func (j *Job) IsRecurring() bool {
+	return j.Cron != ""
+}
+
+// Runner delivers a due job. Errors are logged; recurring jobs keep running.
+type Runner func(job *Job) error
+
+type Scheduler struct {
+	db      *database.Database
+	runner  Runner
+	jobs    map[string]*Job
+	timers  map[string]*time.Timer
+	started bool
+	mu      sync.Mutex
+}
+
+func New(db *database.Database) *Scheduler {
+	return &Scheduler{
+		db:     db,
+		jobs:   make(map[string]*Job),
+		timers: make(map[string]*time.Timer),
+	}
+}
+
+func (s *Scheduler) SetRunner(runner Runner) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.runner = runner
+}
+
+// ParseCron parses a standard five field cron expression or a descriptor
+// such as @daily or @every 1h.
+func ParseCron(spec string) (cron.Schedule, error) {
+	return cron.ParseStandard(spec)
+}
+
+// Start loads the persisted jobs and arms their timers. One-off jobs that
+// came due while the bot was offline run right away.
+func (s *Scheduler) Start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.started {
+		return nil
+	}
+	s.started = true
+
+	for id, raw := range s.db.Get("schedules").Map() {
+		job := &Job{}
+		if err := json.Unmarshal([]byte(raw.Raw), job); err != nil {
+			logger.Error("Failed to load schedule %s: %v", id, err)
+			continue
+		}
+		if job.IsRecurring() {
+			schedule, err := ParseCron(job.Cron)
+			if err != nil {
+				logger.Error("Failed to load schedule %s: %v", id, err)
+				continue
+			}
+			if job.RunAt.Before(time.Now()) {
+				job.RunAt = schedule.Next(time.Now())
+			}
+		}
+		s.jobs[job.ID] = job
+		s.arm(job)
+	}
+
+	logger.Info("⏰ Loaded %d scheduled job(s)", len(s.jobs))
+	return nil
+}
+
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, timer := range s.timers {
+		timer.Stop()
+		delete(s.timers, id)
+	}
+	s.started = false
+}
+
+// Add validates, persists and schedules a job. RunAt is computed from Cron
+// for recurring jobs.
+func (s *Scheduler) Add(job *Job) error {
+	if job.Chat == "" || job.Text == "" {
+		return fmt.Errorf("job needs a chat and a text")
+	}
+
+	if job.IsRecurring() {
+		schedule, err := ParseCron(job.Cron)
+		if err != nil {
+			return fmt.Errorf("invalid cron expression: %w", err)
+		}
+		job.RunAt = schedule.Next(time.Now())
+	} else if job.RunAt.IsZero() {
+		return fmt.Errorf("job needs a time to run at")
+	}
+
+	id, err := newID()
+	if err != nil {
+		return err
+	}
+	job.ID = id
+	job.Created = time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.save(job); err != nil {
+		return err
+	}
+	s.jobs[job.ID] = job
+	if s.started {
+		s.arm(job)
+	}
+	return nil
+}
+
+func (s *Scheduler) Cancel(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.jobs[id]; !exists {
+		return fmt.Errorf("no scheduled job with id %s", id)
+	}
+	return s.remove(id)
+}
+
+func (s *Scheduler) Get(id string) (*Job, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, exists := s.jobs[id]
+	if !exists {
+		return nil, false
+	}
+	copied := *job
+	return &copied, true
+}
+
+// List returns copies of all jobs matching filter, soonest first. A nil
+// filter returns every job.
+func (s *Scheduler) List(filter func(*Job) bool) []*Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var result []*Job
+	for _, job := range s.jobs {
+		if filter == nil || filter(job) {
+			copied := *job
+			result = append(result, &copied)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].RunAt.Before(result[j].RunAt)
+	})
+	return result
+}
+
+func (s *Scheduler) arm(job *Job) {
+	if timer, exists := s.timers[job.ID]; exists {
+		timer.Stop()
+	}
+
+	id := job.ID
+	s.timers[id] = time.AfterFunc(time.Until(job.RunAt), func() {
+		s.fire(id)
+	})
+}
+
+func (s *Scheduler) fire(id string) {
+	s.mu.Lock()
+	job, exists := s.jobs[id]
+	if !exists || !s.started {
+		s.mu.Unlock()
+		return
+	}
+	copied := *job
+	runner := s.runner
+
+	if job.IsRecurring() {
+		schedule, err := ParseCron(job.Cron)
+		if err == nil {
+			job.RunAt = schedule.Next(time.Now())
+			if err := s.save(job); err != nil {
+				logger.Error("Failed to save schedule %s: %v", id, err)
+			}
+			s.arm(job)
+		} else {
+			logger.Error("Dropping schedule %s: %v", id, err)
+			s.remove(id)
+		}
+	} else if err := s.remove(id); err != nil {
+		logger.Error("Failed to remove schedule %s: %v", id, err)
+	}
+	s.mu.Unlock()
+
+	if runner == nil {
+		logger.Warning("⏰ Schedule %s is due but no runner is set", id)
+		return
+	}
+
+	logger.Info("⏰ Running schedule %s in %s", id, copied.Chat)
+	if err := runner(&copied); err != nil {
+		logger.Error("Schedule %s failed: %v", id, err)
+	}
+}
+
+// save stores a job right away even in write-behind mode, so a restart
+// never loses or repeats a job.
+func (s *Scheduler) save(job *Job) error {
+	if err := s.db.Set("schedules."+database.EscapeKey(job.ID), job); err != nil {
+		return err
+	}
+	return s.db.Sync()
+}
+
+func (s *Scheduler) remove(id string) error {
+	if timer, exists := s.timers[id]; exists {
+		timer.Stop()
+		delete(s.timers, id)
+	}
+	delete(s.jobs, id)
+	if err := s.db.Delete("schedules." + database.EscapeKey(id)); err != nil {
+		return err
+	}
+	return s.db.Sync()
+}
+
+// newID returns a random id. The letter keeps it from ever being all
+// digits, which would be an array index in the database path.
+func newID() (string, error) {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "j" + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,37 @@
+package scheduler
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"yukii-bot/lib/database"
+)
+
+func TestJobsAreStoredAsObject(t *testing.T) {
+	db, err := database.Init(filepath.Join(t.TempDir(), "database.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	s := New(db)
+	for i := 0; i < 50; i++ {
+		job := &Job{Chat: "120363025246125888@g.us", Text: "hi", RunAt: time.Now().Add(time.Hour)}
+		if err := s.Add(job); err != nil {
+			t.Fatal(err)
+		}
+		if !db.Get("schedules").IsObject() {
+			t.Fatalf("schedules is not an object after job %s", job.ID)
+		}
+		if _, exists := s.Get(job.ID); !exists {
+			t.Errorf("job %s not found", job.ID)
+		}
+		if err := s.Cancel(job.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := len(db.GetMap("schedules")); n != 0 {
+		t.Errorf("%d jobs left after cancelling all", n)
+	}
+}
@@ -0,0 +1,228 @@
+package session
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sync"
+
+	"yukii-bot/lib/config"
+	"yukii-bot/lib/database"
+	"yukii-bot/lib/logger"
+	"yukii-bot/lib/scheduler"
+	"yukii-bot/lib/whatsapp"
+	"yukii-bot/plugins"
+)
+
+// nameRegex requires a leading letter: the name is a key of the database
+// path accounts.<name>, where numbers and a leading - mean array indexes.
+var nameRegex = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,31}$`)
+
+// Session is one running account with its own client, plugins and jobs.
+type Session struct {
+	Config    config.Session
+	Client    *whatsapp.Client
+	Manager   *plugins.Manager
+	Scheduler *scheduler.Scheduler
+	Database  *database.Database
+}
+
+// Pool runs every configured account and implements
+// plugins.SessionController for the session command.
+type Pool struct {
+	ctx      context.Context
+	cfg      *config.Config
+	db       *database.Database
+	sessions map[string]*Session
+	order    []string
+	mu       sync.Mutex
+}
+
+func NewPool(ctx context.Context, cfg *config.Config, db *database.Database) *Pool {
+	return &Pool{
+		ctx:      ctx,
+		cfg:      cfg,
+		db:       db,
+		sessions: make(map[string]*Session),
+	}
+}
+
+// Database returns the namespace of an account. The default account uses
+// the root so data from single account setups stays where it was.
+func (p *Pool) Database(name string) *database.Database {
+	if name == config.DefaultSession {
+		return p.db
+	}
+	return p.db.Namespace(name)
+}
+
+// Create builds the client, plugin manager and scheduler of an account
+// without connecting it.
+func (p *Pool) Create(sc config.Session) (*Session, error) {
+	if !nameRegex.MatchString(sc.Name) {
+		return nil, fmt.Errorf("invalid session name %q, start with a letter and use a-z, 0-9, _ and -", sc.Name)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, exists := p.sessions[sc.Name]; exists {
+		return nil, fmt.Errorf("session %s already exists", sc.Name)
+	}
+
+	db := p.Database(sc.Name)
+	client, err := whatsapp.NewClient(p.cfg, sc, db)
+	if err != nil {
+		return nil, err
+	}
+
+	manager := plugins.NewManager(client, db)
+	jobs := scheduler.New(db)
+	manager.SetScheduler(jobs)
+	manager.SetSessions(p)
+	if err := manager.LoadPlugins(); err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	client.SetMessageHandler(manager.HandleMessage)
+	client.SetGroupEventHandler(manager.HandleGroupEvent)
+
+	s := &Session{
+		Config:    sc,
+		Client:    client,
+		Manager:   manager,
+		Scheduler: jobs,
+		Database:  db,
+	}
+	p.sessions[sc.Name] = s
+	p.order = append(p.order, sc.Name)
+	return s, nil
+}
+
+// Start connects an account, then keeps it connected and runs its jobs.
+// When the first connect fails the supervisor keeps trying, the error is
+// only returned to be logged.
+func (p *Pool) Start(s *Session) error {
+	logger.Info("📱 Starting session %s", s.Config.Name)
+
+	connectErr := s.Client.ConnectWithRetry(5)
+	s.Client.Supervise(p.ctx)
+
+	if err := s.Scheduler.Start(); err != nil {
+		return fmt.Errorf("session %s: %w", s.Config.Name, err)
+	}
+	if connectErr != nil {
+		return fmt.Errorf("session %s: %w, retrying in the background", s.Config.Name, connectErr)
+	}
+	return nil
+}
+
+func (p *Pool) Get(name string) (*Session, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	s, exists := p.sessions[name]
+	return s, exists
+}
+
+func (p *Pool) ListSessions() []plugins.SessionInfo {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	result := make([]plugins.SessionInfo, 0, len(p.order))
+	for _, name := range p.order {
+		s := p.sessions[name]
+		info := plugins.SessionInfo{
+			Name:   name,
+			Prefix: p.cfg.SessionPrefix(s.Config),
+			State:  s.Client.State(),
+		}
+		if jid := s.Client.GetJID(); !jid.IsEmpty() {
+			info.JID = jid.User
+		}
+		result = append(result, info)
+	}
+	return result
+}
+
+// AddSession saves a new account to the config and starts it. Logging in
+// happens in the background; the QR code shows up in the terminal, pairing
+// codes also go to onPair.
+func (p *Pool) AddSession(sc config.Session, onPair whatsapp.PairHandler) error {
+	s, err := p.Create(sc)
+	if err != nil {
+		return err
+	}
+	if onPair != nil {
+		s.Client.SetPairHandler(onPair)
+	}
+
+	p.mu.Lock()
+	p.cfg.Sessions = append(p.cfg.GetSessions(), sc)
+	err = config.Save(p.cfg, config.Path)
+	p.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		if err := p.Start(s); err != nil {
+			logger.Error("Failed to start session: %v", err)
+		}
+	}()
+	return nil
+}
+
+// RemoveSession stops an account and drops it from the config. Its session
+// files and data are kept, so adding it again resumes without a new login.
+func (p *Pool) RemoveSession(name string) error {
+	p.mu.Lock()
+	s, exists := p.sessions[name]
+	if !exists {
+		p.mu.Unlock()
+		return fmt.Errorf("no session named %s", name)
+	}
+	if len(p.sessions) == 1 {
+		p.mu.Unlock()
+		return fmt.Errorf("can't remove the last session")
+	}
+
+	delete(p.sessions, name)
+	for i, n := range p.order {
+		if n == name {
+			p.order = append(p.order[:i], p.order[i+1:]...)
+			break
+		}
+	}
+
+	var remaining []config.Session
+	for _, sc := range p.cfg.GetSessions() {
+		if sc.Name != name {
+			remaining = append(remaining, sc)
+		}
+	}
+	p.cfg.Sessions = remaining
+	err := config.Save(p.cfg, config.Path)
+	p.mu.Unlock()
+
+	s.Scheduler.Stop()
+	s.Client.Close()
+	logger.Info("📴 Session %s removed", name)
+	return err
+}
+
+// StopAll disconnects every account, e.g. on shutdown.
+func (p *Pool) StopAll() {
+	p.mu.Lock()
+	sessions := make([]*Session, 0, len(p.sessions))
+	for _, name := range p.order {
+		sessions = append(sessions, p.sessions[name])
+	}
+	p.mu.Unlock()
+
+	for _, s := range sessions {
+		s.Scheduler.Stop()
+		s.Client.Disconnect()
+	}
+}
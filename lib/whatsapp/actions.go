@@ -0,0 +1,103 @@
+package whatsapp
+
+import (
+	"yukii-bot/lib/logger"
+
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"google.golang.org/protobuf/proto"
+)
+
+// SendTextMessage is SendMessage for callers that need the ID of the sent
+// message, e.g. to edit or revoke it later.
+func (c *Client) SendTextMessage(to types.JID, text string) (types.MessageID, error) {
+	msg := &waE2E.Message{
+		Conversation: proto.String(text),
+	}
+
+	id, err := c.send(to, msg)
+	if err != nil {
+		return "", err
+	}
+
+	recipient := c.getDisplayName(to)
+	logger.MessageOut(recipient, "text", text, to.String())
+
+	return id, nil
+}
+
+// React sets emoji as the bot's reaction to msg. An empty emoji removes it.
+func (c *Client) React(msg *Message, emoji string) error {
+	sender := msg.Sender
+	if msg.IsFromMe {
+		sender = types.EmptyJID
+	}
+
+	_, err := c.sendWithPriority(msg.From, c.client().BuildReaction(msg.From, sender, msg.ID, emoji), PriorityHigh)
+	if err != nil {
+		return err
+	}
+
+	recipient := c.getDisplayName(msg.From)
+	logger.MessageOut(recipient, "reaction", emoji, msg.From.String())
+
+	return nil
+}
+
+// EditMessage replaces the text of a message the bot sent earlier in chat.
+func (c *Client) EditMessage(chat types.JID, msgID types.MessageID, text string) error {
+	content := &waE2E.Message{
+		Conversation: proto.String(text),
+	}
+
+	_, err := c.sendWithPriority(chat, c.client().BuildEdit(chat, msgID, content), PriorityHigh)
+	if err != nil {
+		return err
+	}
+
+	recipient := c.getDisplayName(chat)
+	logger.MessageOut(recipient, "edit", text, chat.String())
+
+	return nil
+}
+
+// Revoke deletes msg for everyone. Revoking messages of other users only
+// works in groups where the bot is an admin. For an edit, the edited message
+// is revoked.
+func (c *Client) Revoke(msg *Message) error {
+	if msg.Edit != nil {
+		return c.RevokeByID(msg.From, msg.Sender, msg.Edit.Target.ID)
+	}
+	return c.RevokeByID(msg.From, msg.Sender, msg.ID)
+}
+
+func (c *Client) RevokeByID(chat, sender types.JID, msgID types.MessageID) error {
+	_, err := c.sendWithPriority(chat, c.client().BuildRevoke(chat, sender, msgID), PriorityHigh)
+	if err != nil {
+		return err
+	}
+
+	recipient := c.getDisplayName(chat)
+	logger.MessageOut(recipient, "revoke", msgID, chat.String())
+
+	return nil
+}
+
+// MarkRead sends a read receipt (blue ticks) for msg.
+func (c *Client) MarkRead(msg *Message) error {
+	sender := types.EmptyJID
+	if msg.IsGroup {
+		sender = msg.Sender
+	}
+	return c.client().MarkRead([]types.MessageID{msg.ID}, msg.Timestamp, msg.From, sender)
+}
+
+// IsEvent reports whether the message is a reaction, edit, revoke or poll
+// vote rather than new content, so it should not be treated as a command.
+func (m *Message) IsEvent() bool {
+	switch m.Type {
+	case "reaction", "edit", "revoke", "poll_vote":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,294 @@
+package whatsapp
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"yukii-bot/lib/config"
+	"yukii-bot/lib/logger"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// A backup is a tar.gz with a manifest and a snapshot of session.db,
+// encrypted with AES-256-GCM under a key derived from a passphrase:
+//
+//	magic | salt (16) | nonce (12) | ciphertext
+var backupMagic = []byte("YUKIIBAK1")
+
+const (
+	backupSaltSize     = 16
+	backupManifestFile = "manifest.json"
+	backupStoreFile    = "session.db"
+	sqliteHeader       = "SQLite format 3\x00"
+)
+
+var ErrBadPassphrase = errors.New("wrong passphrase or damaged backup")
+
+type backupManifest struct {
+	Session string    `json:"session"`
+	JID     string    `json:"jid,omitempty"`
+	Created time.Time `json:"created"`
+}
+
+// Backup writes an encrypted snapshot of the session store to w. The
+// snapshot is consistent even while the client is connected.
+func (c *Client) Backup(w io.Writer, passphrase string) error {
+	if passphrase == "" {
+		return fmt.Errorf("passphrase is required")
+	}
+
+	if !isSQLite(c.config) {
+		return ErrNotSQLite
+	}
+
+	snapshot, err := snapshotStore(c.config.SessionStoreFile(c.session.Name))
+	if err != nil {
+		return fmt.Errorf("failed to snapshot session store: %w", err)
+	}
+
+	manifest := backupManifest{Session: c.session.Name, Created: time.Now()}
+	if id := c.client().Store.ID; id != nil {
+		manifest.JID = id.User
+	}
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	var archive bytes.Buffer
+	gz := gzip.NewWriter(&archive)
+	tw := tar.NewWriter(gz)
+	for _, file := range []struct {
+		name string
+		data []byte
+	}{
+		{backupManifestFile, manifestData},
+		{backupStoreFile, snapshot},
+	} {
+		header := &tar.Header{Name: file.name, Mode: 0600, Size: int64(len(file.data)), ModTime: manifest.Created}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := tw.Write(file.data); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+
+	sealed, err := sealBackup(archive.Bytes(), passphrase)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(sealed)
+	return err
+}
+
+// RestoreSession replaces the session store of an account with a backup.
+// It fails while a process uses the store, and when a store already
+// exists unless force is set.
+func RestoreSession(cfg *config.Config, name string, r io.Reader, passphrase string, force bool) error {
+	if !isSQLite(cfg) {
+		return ErrNotSQLite
+	}
+
+	sessionDir := cfg.SessionDir(name)
+	if err := os.MkdirAll(sessionDir, 0755); err != nil {
+		return err
+	}
+
+	lock, err := lockStore(sessionDir)
+	if err != nil {
+		return err
+	}
+	defer lock.unlock()
+
+	path := cfg.SessionStoreFile(name)
+	if _, err := os.Stat(path); err == nil && !force {
+		return fmt.Errorf("%s already exists, use force to replace it", path)
+	}
+
+	sealed, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	archive, err := openBackup(sealed, passphrase)
+	if err != nil {
+		return err
+	}
+	snapshot, manifest, err := readBackupArchive(archive)
+	if err != nil {
+		return err
+	}
+	if manifest.Session != name {
+		// Not an error, accounts may be renamed on the new machine.
+		logger.Info("Restoring the backup of session %s as %s", manifest.Session, name)
+	}
+
+	tmp := path + ".restore"
+	if err := os.WriteFile(tmp, snapshot, 0600); err != nil {
+		return err
+	}
+	// Journal files of the old store would be replayed onto the new one.
+	os.Remove(path + "-wal")
+	os.Remove(path + "-shm")
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+func isSQLite(cfg *config.Config) bool {
+	driver, _, err := cfg.SessionStore(config.Session{})
+	return err == nil && driver == "sqlite3"
+}
+
+// snapshotStore copies a SQLite database with VACUUM INTO, which reads a
+// consistent state without blocking writers.
+func snapshotStore(path string) ([]byte, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+
+	dir, err := os.MkdirTemp("", "yukii-backup")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?mode=ro", path))
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	target := filepath.Join(dir, backupStoreFile)
+	if _, err := db.Exec("VACUUM INTO ?", target); err != nil {
+		return nil, err
+	}
+	return os.ReadFile(target)
+}
+
+func backupKey(passphrase string, salt []byte) ([]byte, error) {
+	return scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, 32)
+}
+
+func sealBackup(data []byte, passphrase string) ([]byte, error) {
+	salt := make([]byte, backupSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	key, err := backupKey(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	header := append(append(append([]byte{}, backupMagic...), salt...), nonce...)
+	// The header is authenticated too, so it can't be swapped.
+	return gcm.Seal(header, nonce, data, header), nil
+}
+
+func openBackup(sealed []byte, passphrase string) ([]byte, error) {
+	if !bytes.HasPrefix(sealed, backupMagic) {
+		return nil, fmt.Errorf("not a session backup")
+	}
+
+	rest := sealed[len(backupMagic):]
+	if len(rest) < backupSaltSize {
+		return nil, ErrBadPassphrase
+	}
+	salt := rest[:backupSaltSize]
+	key, err := backupKey(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	headerSize := len(backupMagic) + backupSaltSize + gcm.NonceSize()
+	if len(sealed) < headerSize {
+		return nil, ErrBadPassphrase
+	}
+	header := sealed[:headerSize]
+	nonce := header[len(backupMagic)+backupSaltSize:]
+	data, err := gcm.Open(nil, nonce, sealed[headerSize:], header)
+	if err != nil {
+		return nil, ErrBadPassphrase
+	}
+	return data, nil
+}
+
+func readBackupArchive(archive []byte) ([]byte, backupManifest, error) {
+	var manifest backupManifest
+	var snapshot []byte
+
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return nil, manifest, err
+	}
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, manifest, err
+		}
+
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, manifest, err
+		}
+		switch header.Name {
+		case backupManifestFile:
+			if err := json.Unmarshal(data, &manifest); err != nil {
+				return nil, manifest, fmt.Errorf("invalid backup manifest: %w", err)
+			}
+		case backupStoreFile:
+			snapshot = data
+		}
+	}
+
+	if !strings.HasPrefix(string(snapshot), sqliteHeader) {
+		return nil, manifest, fmt.Errorf("backup doesn't contain a session store")
+	}
+	return snapshot, manifest, nil
+}
@@ -7,6 +7,7 @@ import (
 	"regexp"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"yukii-bot/lib/config"
@@ -33,15 +34,33 @@ const (
 type MessageHandler func(*Message) error
 
 type Client struct {
-	client         *whatsmeow.Client
-	config         *config.Config
-	db             *database.Database
-	authMode       AuthMode
-	pairCode       string
-	messageHandler MessageHandler
-	eventHandlers  map[string]func(interface{})
-	loginMutex     sync.RWMutex
-	isConnecting   bool
+	// wa is swapped when a logged out session starts over, so it's only
+	// read through client().
+	wa                atomic.Pointer[whatsmeow.Client]
+	container         *sqlstore.Container
+	lock              *storeLock
+	config            *config.Config
+	session           config.Session
+	db                *database.Database
+	authMode          AuthMode
+	pairCode          string
+	pairHandler       PairHandler
+	messageHandler    MessageHandler
+	groupEventHandler GroupEventHandler
+	eventHandlers     map[string]func(interface{})
+	loginMutex        sync.RWMutex
+	isConnecting      bool
+	groups            *groupCache
+
+	state             ConnectionState
+	subscribers       map[chan ConnectionState]struct{}
+	reconnectAttempts int
+	stopSupervisor    context.CancelFunc
+	stateMu           sync.RWMutex
+
+	queue    *sendQueue
+	outbox   []queuedMessage
+	outboxMu sync.Mutex
 }
 
 type Message struct {
@@ -53,30 +72,66 @@ type Message struct {
 	IsGroup   bool
 	GroupInfo *types.GroupInfo
 	Sender    types.JID
+	SenderAlt types.JID
 	Timestamp time.Time
 	IsFromMe  bool
+	Mentions  []types.JID
+
+	QuotedID      string
+	QuotedSender  types.JID
+	QuotedMessage *waE2E.Message
+
+	IsEphemeral bool
+	IsViewOnce  bool
+	IsEdit      bool
+
+	Poll      *Poll
+	PollVote  *PollVote
+	Reaction  *Reaction
+	Edit      *Edit
+	Revoked   *MessageRef
+	Location  *Location
+	Contacts  []Contact
+	Selection *Selection
 
 	Raw *events.Message
 }
 
-func NewClient(cfg *config.Config, db *database.Database) (*Client, error) {
+// NewClient creates the client of one account. db should be the account's
+// namespace of the database.
+func NewClient(cfg *config.Config, session config.Session, db *database.Database) (*Client, error) {
 	dbLog := waLog.Noop
 	if cfg.WhatsApp.LogLevel == "DEBUG" {
 		dbLog = waLog.Stdout("Database", "DEBUG", true)
 	}
 
-	if err := os.MkdirAll(cfg.WhatsApp.SessionPath, 0755); err != nil {
+	sessionDir := cfg.SessionDir(session.Name)
+	if err := os.MkdirAll(sessionDir, 0755); err != nil {
+		return nil, err
+	}
+	
+	lock, err := lockStore(sessionDir)
+	if err != nil {
+		return nil, fmt.Errorf("session %s: %w", session.Name, err)
+	}
+	
+	driver, dsn, err := cfg.SessionStore(session)
+	if err != nil {
+		lock.unlock()
 		return nil, err
 	}
 	
 	ctx := context.Background()
-	container, err := sqlstore.New(ctx, "sqlite3", fmt.Sprintf("file:%s/session.db?_foreign_keys=on", cfg.WhatsApp.SessionPath), dbLog)
+	container, err := sqlstore.New(ctx, driver, dsn, dbLog)
 	if err != nil {
+		lock.unlock()
 		return nil, err
 	}
 
 	deviceStore, err := container.GetFirstDevice(ctx)
 	if err != nil {
+		container.Close()
+		lock.unlock()
 		return nil, err
 	}
 
@@ -86,14 +141,35 @@ func NewClient(cfg *config.Config, db *database.Database) (*Client, error) {
 	}
 	
 	client := whatsmeow.NewClient(deviceStore, clientLog)
+	// Reconnects are handled by Supervise so they share one backoff and
+	// show up in the connection state.
+	client.EnableAutoReconnect = false
 	
-	return &Client{
-		client:        client,
+	c := &Client{
+		container:     container,
+		lock:          lock,
 		config:        cfg,
+		session:       session,
 		db:            db,
 		authMode:      AuthModeAuto,
 		eventHandlers: make(map[string]func(interface{})),
-	}, nil
+		groups:        newGroupCache(time.Duration(cfg.WhatsApp.GroupCacheTTL) * time.Second),
+		subscribers:   make(map[chan ConnectionState]struct{}),
+	}
+	if session.Phone != "" {
+		c.authMode = AuthModePair
+		c.pairCode = session.Phone
+	}
+	c.wa.Store(client)
+	c.queue = newSendQueue(c)
+	client.AddEventHandler(c.handleEvent)
+	
+	return c, nil
+}
+
+// client returns the whatsmeow client of the current device.
+func (c *Client) client() *whatsmeow.Client {
+	return c.wa.Load()
 }
 
 func (c *Client) SetAuthMode(mode AuthMode) {
@@ -116,17 +192,19 @@ func (c *Client) Connect() error {
 		return fmt.Errorf("already connecting")
 	}
 
-	c.client.AddEventHandler(c.handleEvent)
+	c.setState(StateConnecting)
 
-	if c.client.Store.ID == nil {
+	if c.client().Store.ID == nil {
 		c.isConnecting = true
 		defer func() { c.isConnecting = false }()
 		
 		if err := c.login(); err != nil {
+			c.setState(StateDisconnected)
 			return err
 		}
-	} else {
-		return c.client.Connect()
+	} else if err := c.client().Connect(); err != nil {
+		c.setState(StateDisconnected)
+		return err
 	}
 	
 	return nil
@@ -143,130 +221,41 @@ func (c *Client) login() error {
 	}
 }
 
-func (c *Client) loginQR() error {
-	qrChan, err := c.client.GetQRChannel(context.Background())
-	if err != nil {
-		return err
-	}
-
-	if err := c.client.Connect(); err != nil {
-		return err
-	}
-	
-	logger.Info("📱 Please scan the QR code below:")
-	
-	for evt := range qrChan {
-		if evt.Event == "code" {
-			fmt.Println(evt.Code)
-		} else {
-			logger.Info("QR Channel event: %s", evt.Event)
-		}
-	}
-	
-	return nil
-}
-
-func (c *Client) loginPair() error {
-	if c.pairCode == "" {
-		return fmt.Errorf("pair code is required")
-	}
-	
-	loginChan := make(chan bool, 1)
-	errorChan := make(chan error, 1)
-	
-	c.client.AddEventHandler(func(evt interface{}) {
-		switch e := evt.(type) {
-		case *events.Connected:
-			logger.Info("🔗 Connected to WhatsApp")
-			time.Sleep(2 * time.Second)
-			if c.client.Store.ID != nil {
-				logger.Info("✅ Successfully logged in!")
-				select {
-				case loginChan <- true:
-				default:
-				}
-			}
-		case *events.LoggedOut:
-			logger.Info("❌ Logged out from WhatsApp")
-			select {
-			case loginChan <- false:
-			default:
-			}
-		case *events.Disconnected:
-			logger.Info("⚠️ Disconnected from WhatsApp")
-		case *events.ConnectFailure:
-			logger.Error("❌ Connection failed: %v", e.Reason)
-			select {
-			case errorChan <- fmt.Errorf("connection failed: %v", e.Reason):
-			default:
-			}
-		}
-	})
-	
-	if err := c.client.Connect(); err != nil {
-		return fmt.Errorf("failed to connect: %v", err)
-	}
-	
-	logger.Info("⏳ Waiting for connection...")
-	time.Sleep(3 * time.Second)
-	
-	if c.client.Store.ID != nil {
-		logger.Info("✅ Already authenticated!")
-		return nil
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-	
-	logger.Info("🔄 Starting pairing process...")
-	code, err := c.client.PairPhone(ctx, c.pairCode, true, whatsmeow.PairClientChrome, "Chrome (Linux)")
-	if err != nil {
-		return fmt.Errorf("failed to pair phone: %v", err)
-	}
-	
-	logger.Info("🔐 Pairing code: %s", code)
-	logger.Info("⏳ Waiting for authentication... (this may take up to 5 minutes)")
-	
-	timeout := time.After(5 * time.Minute) 
-	
-	for {
-		select {
-		case success := <-loginChan:
-			if success {
-				logger.Info("✅ Login successful!")
-				return nil
-			} else {
-				return fmt.Errorf("authentication failed - logged out")
-			}
-		case err := <-errorChan:
-			return fmt.Errorf("connection error: %v", err)
-		case <-timeout:
-			return fmt.Errorf("authentication timeout - please try again")
-		case <-time.After(15 * time.Second):
-			if !c.client.IsConnected() {
-				logger.Info("⚠️ Connection lost, reconnecting...")
-				if err := c.client.Connect(); err != nil {
-					logger.Error("Failed to reconnect: %v", err)
-				}
-			} else {
-				logger.Info("⏳ Still waiting for authentication...")
-			}
-		}
-	}
-}
-
 func (c *Client) handleEvent(evt interface{}) {
 	switch e := evt.(type) {
 	case *events.Message:
 		c.handleMessage(e)
+	case *events.GroupInfo:
+		c.handleGroupInfo(e)
+	case *events.JoinedGroup:
+		c.groups.set(&e.GroupInfo)
 	case *events.Connected:
 		logger.Connection("connected")
+		c.setState(StateConnected)
+		go c.flushOutbox()
+		if c.config.WhatsApp.Online {
+			go func() {
+				if err := c.SetOnline(true); err != nil {
+					logger.Debug("Failed to set presence: %v", err)
+				}
+			}()
+		}
 	case *events.Disconnected:
 		logger.Connection("disconnected")
+		if c.State() != StateLoggedOut {
+			c.setState(StateDisconnected)
+		}
 	case *events.LoggedOut:
 		logger.Connection("logged out")
+		c.setState(StateLoggedOut)
+	case *events.StreamReplaced:
+		logger.Warning("⚠️ Session was opened somewhere else, not reconnecting")
+		c.setState(StateReplaced)
 	case *events.ConnectFailure:
 		logger.Error("Connection failure: %v", e.Reason)
+		if !e.Reason.IsLoggedOut() {
+			c.setState(StateDisconnected)
+		}
 	case *events.ClientOutdated:
 		logger.Error("Client outdated - please update whatsmeow")
 	}
@@ -296,6 +285,7 @@ func (c *Client) convertMessage(evt *events.Message) *Message {
 		ID:        evt.Info.ID,
 		From:      evt.Info.Chat,
 		Sender:    evt.Info.Sender,
+		SenderAlt: evt.Info.SenderAlt,
 		Timestamp: evt.Info.Timestamp,
 		IsFromMe:  evt.Info.IsFromMe,
 		IsGroup:   evt.Info.IsGroup,
@@ -303,46 +293,74 @@ func (c *Client) convertMessage(evt *events.Message) *Message {
 	}
 	
 	if msg.IsGroup {
-		msg.GroupInfo = &types.GroupInfo{
-			JID: evt.Info.Chat,
+		msg.GroupInfo = c.groups.get(evt.Info.Chat)
+		if msg.GroupInfo == nil {
+			msg.GroupInfo = &types.GroupInfo{
+				JID: evt.Info.Chat,
+			}
 		}
 	}
 	
+	m, ephemeral, viewOnce := unwrapMessage(evt.Message)
+	msg.IsEphemeral = evt.IsEphemeral || ephemeral
+	msg.IsViewOnce = evt.IsViewOnce || viewOnce
+	msg.IsEdit = evt.IsEdit
+	
+	// Polls, reactions, edits and the other special types are classified
+	// first, so the text and media cases below don't claim a message that
+	// also carries a body, such as an edit with its new Conversation text.
+	special := c.convertSpecial(msg, evt, m)
+	
 	switch {
-	case evt.Message.Conversation != nil:
-		msg.Body = *evt.Message.Conversation
+	case special:
+	case m.Conversation != nil:
+		msg.Body = m.GetConversation()
 		msg.Type = "text"
-	case evt.Message.ExtendedTextMessage != nil:
-		msg.Body = *evt.Message.ExtendedTextMessage.Text
+	case m.ExtendedTextMessage != nil:
+		msg.Body = m.GetExtendedTextMessage().GetText()
 		msg.Type = "text"
-	case evt.Message.ImageMessage != nil:
+	case m.ImageMessage != nil:
 		msg.Body = "[Image]"
-		if evt.Message.ImageMessage.Caption != nil {
-			msg.Body = *evt.Message.ImageMessage.Caption
+		if m.ImageMessage.Caption != nil {
+			msg.Body = *m.ImageMessage.Caption
 		}
 		msg.Type = "image"
-	case evt.Message.VideoMessage != nil:
+	case m.VideoMessage != nil:
 		msg.Body = "[Video]"
-		if evt.Message.VideoMessage.Caption != nil {
-			msg.Body = *evt.Message.VideoMessage.Caption
+		if m.VideoMessage.Caption != nil {
+			msg.Body = *m.VideoMessage.Caption
 		}
 		msg.Type = "video"
-	case evt.Message.AudioMessage != nil:
+	case m.AudioMessage != nil:
 		msg.Body = "[Audio]"
 		msg.Type = "audio"
-	case evt.Message.DocumentMessage != nil:
+	case m.DocumentMessage != nil:
 		msg.Body = "[Document]"
-		if evt.Message.DocumentMessage.Title != nil {
-			msg.Body = *evt.Message.DocumentMessage.Title
+		if m.DocumentMessage.Caption != nil {
+			msg.Body = *m.DocumentMessage.Caption
+		} else if m.DocumentMessage.Title != nil {
+			msg.Body = *m.DocumentMessage.Title
 		}
 		msg.Type = "document"
-	case evt.Message.StickerMessage != nil:
+	case m.StickerMessage != nil:
 		msg.Body = "[Sticker]"
 		msg.Type = "sticker"
-	case evt.Message.LocationMessage != nil:
+	case m.LocationMessage != nil:
+		location := m.LocationMessage
+		msg.Location = &Location{
+			Latitude:  location.GetDegreesLatitude(),
+			Longitude: location.GetDegreesLongitude(),
+			Name:      location.GetName(),
+			Address:   location.GetAddress(),
+			IsLive:    location.GetIsLive(),
+		}
 		msg.Body = "[Location]"
 		msg.Type = "location"
-	case evt.Message.ContactMessage != nil:
+	case m.ContactMessage != nil:
+		msg.Contacts = []Contact{{
+			DisplayName: m.ContactMessage.GetDisplayName(),
+			VCard:       m.ContactMessage.GetVcard(),
+		}}
 		msg.Body = "[Contact]"
 		msg.Type = "contact"
 	default:
@@ -350,13 +368,23 @@ func (c *Client) convertMessage(evt *events.Message) *Message {
 		msg.Type = "unknown"
 	}
 	
+	contextInfo := getContextInfo(m)
+	msg.Mentions = parseMentions(contextInfo)
+	if contextInfo.GetQuotedMessage() != nil {
+		msg.QuotedID = contextInfo.GetStanzaID()
+		msg.QuotedMessage, _, _ = unwrapMessage(contextInfo.GetQuotedMessage())
+		if participant, err := types.ParseJID(contextInfo.GetParticipant()); err == nil {
+			msg.QuotedSender = participant
+		}
+	}
+	
 	return msg
 }
 
 func (c *Client) getDisplayName(jid types.JID) string {
 	if jid.Server == types.DefaultUserServer {
 		ctx := context.Background()
-		contact, err := c.client.Store.Contacts.GetContact(ctx, jid)
+		contact, err := c.client().Store.Contacts.GetContact(ctx, jid)
 		if err == nil && contact.FullName != "" {
 			return contact.FullName
 		}
@@ -371,7 +399,7 @@ func (c *Client) SendMessage(to types.JID, text string) error {
 		Conversation: proto.String(text),
 	}
 	
-	_, err := c.client.SendMessage(context.Background(), to, msg)
+	_, err := c.send(to, msg)
 	if err != nil {
 		return err
 	}
@@ -393,7 +421,7 @@ func (c *Client) SendReply(original *Message, text string) error {
 		},
 	}
 	
-	_, err := c.client.SendMessage(context.Background(), original.From, msg)
+	_, err := c.sendWithPriority(original.From, msg, PriorityHigh)
 	if err != nil {
 		return err
 	}
@@ -405,23 +433,38 @@ func (c *Client) SendReply(original *Message, text string) error {
 }
 
 func (c *Client) Disconnect() {
+	c.stopSupervising()
+	
 	c.loginMutex.Lock()
 	defer c.loginMutex.Unlock()
 	
-	if c.client != nil {
-		c.client.Disconnect()
+	if c.client() != nil {
+		if c.client().IsConnected() {
+			c.SetOnline(false)
+		}
+		c.client().Disconnect()
 	}
+	c.setState(StateDisconnected)
+}
+
+func (c *Client) GetConfig() *config.Config {
+	return c.config
+}
+
+// Session returns the configuration of the account this client runs.
+func (c *Client) Session() config.Session {
+	return c.session
 }
 
 func (c *Client) GetJID() types.JID {
-	if c.client.Store.ID == nil {
+	if c.client().Store.ID == nil {
 		return types.JID{}
 	}
-	return *c.client.Store.ID
+	return *c.client().Store.ID
 }
 
 func (c *Client) IsConnected() bool {
-	return c.client.IsConnected()
+	return c.client().IsConnected()
 }
 
 func (c *Client) ConnectWithRetry(maxRetries int) error {
@@ -430,8 +473,8 @@ func (c *Client) ConnectWithRetry(maxRetries int) error {
 	for i := 0; i < maxRetries; i++ {
 		if err := c.Connect(); err != nil {
 			lastErr = err
-			backoff := time.Duration(i+1) * time.Second * 2
-			logger.Info("🔄 Retry %d/%d failed, waiting %v before next attempt", i+1, maxRetries, backoff)
+			backoff := c.reconnectDelay(i)
+			logger.Info("🔄 Retry %d/%d failed, waiting %v before next attempt", i+1, maxRetries, backoff.Round(time.Millisecond))
 			time.Sleep(backoff)
 			continue
 		}
@@ -0,0 +1,232 @@
+package whatsapp
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	"yukii-bot/lib/logger"
+
+	"go.mau.fi/whatsmeow"
+)
+
+type ConnectionState int
+
+const (
+	StateDisconnected ConnectionState = iota
+	StateConnecting
+	StateConnected
+	StateLoggedOut
+	StateReplaced
+)
+
+func (s ConnectionState) String() string {
+	switch s {
+	case StateConnecting:
+		return "connecting"
+	case StateConnected:
+		return "connected"
+	case StateLoggedOut:
+		return "logged out"
+	case StateReplaced:
+		return "replaced"
+	default:
+		return "disconnected"
+	}
+}
+
+const (
+	reconnectBaseDelay = time.Second
+	subscriberBuffer   = 16
+)
+
+func (c *Client) State() ConnectionState {
+	c.stateMu.RLock()
+	defer c.stateMu.RUnlock()
+
+	return c.state
+}
+
+// Subscribe returns a channel receiving every state change and a function
+// to stop the subscription. Slow subscribers miss changes instead of
+// blocking the client.
+func (c *Client) Subscribe() (<-chan ConnectionState, func()) {
+	ch := make(chan ConnectionState, subscriberBuffer)
+
+	c.stateMu.Lock()
+	c.subscribers[ch] = struct{}{}
+	c.stateMu.Unlock()
+
+	return ch, func() {
+		c.stateMu.Lock()
+		defer c.stateMu.Unlock()
+
+		if _, exists := c.subscribers[ch]; exists {
+			delete(c.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+func (c *Client) setState(state ConnectionState) {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+
+	if c.state == state {
+		return
+	}
+	c.state = state
+	if state == StateConnected {
+		c.reconnectAttempts = 0
+	}
+
+	for ch := range c.subscribers {
+		select {
+		case ch <- state:
+		default:
+		}
+	}
+}
+
+// reconnectDelay doubles the delay per attempt up to the configured maximum
+// and picks a random point in its upper half so clients don't reconnect in
+// lockstep.
+func (c *Client) reconnectDelay(attempt int) time.Duration {
+	maxDelay := time.Duration(c.config.WhatsApp.ReconnectMaxDelay) * time.Second
+	if maxDelay <= 0 {
+		maxDelay = 2 * time.Minute
+	}
+
+	delay := reconnectBaseDelay << min(attempt, 16)
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// Supervise keeps the client connected until ctx is done or Disconnect is
+// called: dropped connections are retried with backoff and a logged out
+// session starts a new login with the configured auth mode.
+func (c *Client) Supervise(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	c.stateMu.Lock()
+	if c.stopSupervisor != nil {
+		c.stopSupervisor()
+	}
+	c.stopSupervisor = cancel
+	c.stateMu.Unlock()
+
+	states, unsubscribe := c.Subscribe()
+	go func() {
+		defer unsubscribe()
+
+		for {
+			switch c.State() {
+			case StateDisconnected:
+				c.reconnect(ctx)
+			case StateLoggedOut:
+				c.reauthenticate(ctx)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-states:
+			}
+		}
+	}()
+}
+
+func (c *Client) stopSupervising() {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+
+	if c.stopSupervisor != nil {
+		c.stopSupervisor()
+		c.stopSupervisor = nil
+	}
+}
+
+func (c *Client) waitRetry(ctx context.Context) bool {
+	c.stateMu.Lock()
+	attempt := c.reconnectAttempts
+	c.reconnectAttempts++
+	c.stateMu.Unlock()
+
+	delay := c.reconnectDelay(attempt)
+	logger.Info("🔄 Reconnecting in %v (attempt %d)", delay.Round(time.Millisecond), attempt+1)
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(delay):
+		return true
+	}
+}
+
+func (c *Client) reconnect(ctx context.Context) {
+	for c.State() == StateDisconnected {
+		if !c.waitRetry(ctx) {
+			return
+		}
+		if c.State() != StateDisconnected {
+			return
+		}
+
+		var err error
+		if c.client().Store.ID == nil {
+			// Never logged in, e.g. the first QR code expired unscanned.
+			err = c.Connect()
+		} else {
+			c.setState(StateConnecting)
+			err = c.client().Connect()
+		}
+		if err == nil || errors.Is(err, whatsmeow.ErrAlreadyConnected) {
+			// The Connected or Disconnected event decides what happens next.
+			return
+		}
+		logger.Error("Failed to reconnect: %v", err)
+		c.setState(StateDisconnected)
+	}
+}
+
+func (c *Client) reauthenticate(ctx context.Context) {
+	logger.Warning("🔐 Session was logged out, starting a new login")
+
+	for c.State() == StateLoggedOut {
+		c.loginMutex.Lock()
+		c.resetDevice()
+		c.isConnecting = true
+		err := c.login()
+		c.isConnecting = false
+		c.loginMutex.Unlock()
+
+		if err == nil && c.client().Store.ID != nil {
+			return
+		}
+		if err != nil {
+			logger.Error("Failed to log in again: %v", err)
+		}
+		if !c.waitRetry(ctx) {
+			return
+		}
+	}
+}
+
+// resetDevice swaps the logged out device for a fresh one so a new login
+// can start. whatsmeow has already removed the old device from the store.
+// The send queue is paused meanwhile, so no delivery uses the old client.
+func (c *Client) resetDevice() {
+	c.queue.pause()
+	defer c.queue.resume()
+
+	old := c.client()
+	old.Disconnect()
+
+	client := whatsmeow.NewClient(c.container.NewDevice(), old.Log)
+	client.EnableAutoReconnect = false
+	client.AddEventHandler(c.handleEvent)
+	c.wa.Store(client)
+}
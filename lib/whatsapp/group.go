@@ -0,0 +1,167 @@
+package whatsapp
+
+import (
+	"fmt"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/types"
+)
+
+// GetGroupInfo returns group metadata, fetching it from WhatsApp only when it
+// is not cached or the cached copy expired.
+func (c *Client) GetGroupInfo(jid types.JID) (*types.GroupInfo, error) {
+	if jid.Server != types.GroupServer {
+		return nil, fmt.Errorf("%s is not a group", jid)
+	}
+	return c.groups.load(jid, c.client().GetGroupInfo)
+}
+
+func (c *Client) RefreshGroupInfo(jid types.JID) (*types.GroupInfo, error) {
+	c.groups.invalidate(jid)
+	return c.GetGroupInfo(jid)
+}
+
+func (c *Client) InvalidateGroupInfo(jid types.JID) {
+	c.groups.invalidate(jid)
+}
+
+func (c *Client) GetJoinedGroups() ([]*types.GroupInfo, error) {
+	groups, err := c.client().GetJoinedGroups()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, info := range groups {
+		c.groups.set(info)
+	}
+	return groups, nil
+}
+
+func (c *Client) updateParticipants(group types.JID, users []types.JID, action whatsmeow.ParticipantChange) ([]types.GroupParticipant, error) {
+	if len(users) == 0 {
+		return nil, fmt.Errorf("no participants given")
+	}
+
+	result, err := c.client().UpdateGroupParticipants(group, users, action)
+	c.groups.invalidate(group)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, participant := range result {
+		if participant.Error != 0 {
+			return result, fmt.Errorf("failed to %s %s (error %d)", action, participant.JID.User, participant.Error)
+		}
+	}
+	return result, nil
+}
+
+func (c *Client) AddParticipants(group types.JID, users ...types.JID) ([]types.GroupParticipant, error) {
+	return c.updateParticipants(group, users, whatsmeow.ParticipantChangeAdd)
+}
+
+func (c *Client) KickParticipants(group types.JID, users ...types.JID) ([]types.GroupParticipant, error) {
+	return c.updateParticipants(group, users, whatsmeow.ParticipantChangeRemove)
+}
+
+func (c *Client) PromoteParticipants(group types.JID, users ...types.JID) ([]types.GroupParticipant, error) {
+	return c.updateParticipants(group, users, whatsmeow.ParticipantChangePromote)
+}
+
+func (c *Client) DemoteParticipants(group types.JID, users ...types.JID) ([]types.GroupParticipant, error) {
+	return c.updateParticipants(group, users, whatsmeow.ParticipantChangeDemote)
+}
+
+func (c *Client) SetGroupSubject(group types.JID, subject string) error {
+	defer c.groups.invalidate(group)
+	return c.client().SetGroupName(group, subject)
+}
+
+func (c *Client) SetGroupDescription(group types.JID, description string) error {
+	defer c.groups.invalidate(group)
+	return c.client().SetGroupDescription(group, description)
+}
+
+// SetGroupAnnounce toggles whether only admins can send messages.
+func (c *Client) SetGroupAnnounce(group types.JID, announce bool) error {
+	defer c.groups.invalidate(group)
+	return c.client().SetGroupAnnounce(group, announce)
+}
+
+// SetGroupLocked toggles whether only admins can edit the group info.
+func (c *Client) SetGroupLocked(group types.JID, locked bool) error {
+	defer c.groups.invalidate(group)
+	return c.client().SetGroupLocked(group, locked)
+}
+
+func (c *Client) GetGroupInviteLink(group types.JID) (string, error) {
+	return c.client().GetGroupInviteLink(group, false)
+}
+
+func (c *Client) ResetGroupInviteLink(group types.JID) (string, error) {
+	return c.client().GetGroupInviteLink(group, true)
+}
+
+func (c *Client) LeaveGroup(group types.JID) error {
+	defer c.groups.invalidate(group)
+	return c.client().LeaveGroup(group)
+}
+
+func sameUser(a, b types.JID) bool {
+	return !a.IsEmpty() && !b.IsEmpty() && a.User == b.User
+}
+
+// FindParticipant looks a user up by phone number or LID, so it matches no
+// matter which addressing mode the group uses.
+func FindParticipant(info *types.GroupInfo, users ...types.JID) *types.GroupParticipant {
+	if info == nil {
+		return nil
+	}
+	for i, participant := range info.Participants {
+		for _, user := range users {
+			if sameUser(participant.JID, user) || sameUser(participant.PhoneNumber, user) || sameUser(participant.LID, user) {
+				return &info.Participants[i]
+			}
+		}
+	}
+	return nil
+}
+
+func GroupAdmins(info *types.GroupInfo) []types.JID {
+	var admins []types.JID
+	for _, participant := range info.Participants {
+		if participant.IsAdmin || participant.IsSuperAdmin {
+			admins = append(admins, participant.JID)
+		}
+	}
+	return admins
+}
+
+func IsGroupAdmin(info *types.GroupInfo, users ...types.JID) bool {
+	participant := FindParticipant(info, users...)
+	return participant != nil && (participant.IsAdmin || participant.IsSuperAdmin)
+}
+
+func (c *Client) ownJIDs() []types.JID {
+	if c.client().Store.ID == nil {
+		return nil
+	}
+	return []types.JID{c.client().Store.ID.ToNonAD(), c.client().Store.LID.ToNonAD()}
+}
+
+// SenderJIDs returns every known address of the sender: the JID the message
+// came from and, for LID-addressed groups, the phone number JID.
+func (m *Message) SenderJIDs() []types.JID {
+	if m.SenderAlt.IsEmpty() {
+		return []types.JID{m.Sender}
+	}
+	return []types.JID{m.Sender, m.SenderAlt}
+}
+
+func (c *Client) IsBotGroupAdmin(group types.JID) (bool, error) {
+	info, err := c.GetGroupInfo(group)
+	if err != nil {
+		return false, err
+	}
+	return IsGroupAdmin(info, c.ownJIDs()...), nil
+}
@@ -0,0 +1,123 @@
+package whatsapp
+
+import (
+	"sync"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+const defaultGroupCacheTTL = 10 * time.Minute
+
+type groupCacheEntry struct {
+	info    *types.GroupInfo
+	expires time.Time
+}
+
+type groupFetch struct {
+	done chan struct{}
+	info *types.GroupInfo
+	err  error
+	// stale is set when the group changed during the fetch, so its result
+	// may be outdated and must not be cached.
+	stale bool
+}
+
+// groupCache keeps group metadata in memory so admin checks don't hit
+// WhatsApp on every message. Entries expire after ttl and are dropped
+// whenever a group change event arrives.
+type groupCache struct {
+	ttl      time.Duration
+	groups   map[types.JID]groupCacheEntry
+	inflight map[types.JID]*groupFetch
+	mu       sync.Mutex
+}
+
+func newGroupCache(ttl time.Duration) *groupCache {
+	if ttl <= 0 {
+		ttl = defaultGroupCacheTTL
+	}
+	return &groupCache{
+		ttl:      ttl,
+		groups:   make(map[types.JID]groupCacheEntry),
+		inflight: make(map[types.JID]*groupFetch),
+	}
+}
+
+// get returns the cached metadata, or nil when it is missing or expired.
+func (gc *groupCache) get(jid types.JID) *types.GroupInfo {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+
+	entry, ok := gc.groups[jid]
+	if !ok {
+		return nil
+	}
+	if time.Now().After(entry.expires) {
+		delete(gc.groups, jid)
+		return nil
+	}
+	return entry.info
+}
+
+func (gc *groupCache) set(info *types.GroupInfo) {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+
+	gc.put(info)
+	gc.markStale(info.JID)
+}
+
+func (gc *groupCache) invalidate(jid types.JID) {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+
+	delete(gc.groups, jid)
+	gc.markStale(jid)
+}
+
+// put caches info. The caller holds the lock.
+func (gc *groupCache) put(info *types.GroupInfo) {
+	gc.groups[info.JID] = groupCacheEntry{
+		info:    info,
+		expires: time.Now().Add(gc.ttl),
+	}
+}
+
+// markStale keeps a running fetch of the group out of the cache. The
+// caller holds the lock.
+func (gc *groupCache) markStale(jid types.JID) {
+	if pending, ok := gc.inflight[jid]; ok {
+		pending.stale = true
+	}
+}
+
+// load returns the cached metadata or fetches it. Concurrent loads of the
+// same group share a single fetch.
+func (gc *groupCache) load(jid types.JID, fetch func(types.JID) (*types.GroupInfo, error)) (*types.GroupInfo, error) {
+	if info := gc.get(jid); info != nil {
+		return info, nil
+	}
+
+	gc.mu.Lock()
+	if pending, ok := gc.inflight[jid]; ok {
+		gc.mu.Unlock()
+		<-pending.done
+		return pending.info, pending.err
+	}
+	pending := &groupFetch{done: make(chan struct{})}
+	gc.inflight[jid] = pending
+	gc.mu.Unlock()
+
+	pending.info, pending.err = fetch(jid)
+
+	gc.mu.Lock()
+	if pending.err == nil && !pending.stale {
+		gc.put(pending.info)
+	}
+	delete(gc.inflight, jid)
+	gc.mu.Unlock()
+	close(pending.done)
+
+	return pending.info, pending.err
+}
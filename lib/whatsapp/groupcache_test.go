@@ -0,0 +1,44 @@
+package whatsapp
+
+import (
+	"testing"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+func TestInvalidateDuringFetch(t *testing.T) {
+	gc := newGroupCache(time.Minute)
+	jid := types.NewJID("120363025246125888", types.GroupServer)
+
+	fetched := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		gc.load(jid, func(jid types.JID) (*types.GroupInfo, error) {
+			close(fetched)
+			<-release
+			return &types.GroupInfo{JID: jid, GroupName: types.GroupName{Name: "old"}}, nil
+		})
+	}()
+
+	<-fetched
+	gc.invalidate(jid)
+	close(release)
+	<-done
+
+	if info := gc.get(jid); info != nil {
+		t.Errorf("cached %q from a fetch that started before the change", info.Name)
+	}
+
+	info, err := gc.load(jid, func(jid types.JID) (*types.GroupInfo, error) {
+		return &types.GroupInfo{JID: jid, GroupName: types.GroupName{Name: "new"}}, nil
+	})
+	if err != nil || info.Name != "new" {
+		t.Fatalf("load = %v, %v", info, err)
+	}
+	if cached := gc.get(jid); cached == nil || cached.Name != "new" {
+		t.Errorf("fresh fetch was not cached")
+	}
+}
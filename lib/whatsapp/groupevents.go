@@ -0,0 +1,99 @@
+package whatsapp
+
+import (
+	"time"
+
+	"yukii-bot/lib/logger"
+
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+const (
+	GroupEventJoin        = "join"
+	GroupEventLeave       = "leave"
+	GroupEventPromote     = "promote"
+	GroupEventDemote      = "demote"
+	GroupEventSubject     = "subject"
+	GroupEventDescription = "description"
+)
+
+type GroupEvent struct {
+	Type      string
+	Group     types.JID
+	Actor     types.JID
+	Users     []types.JID
+	Subject   string
+	Reason    string
+	Timestamp time.Time
+
+	Raw *events.GroupInfo
+}
+
+type GroupEventHandler func(*GroupEvent) error
+
+func (c *Client) SetGroupEventHandler(handler GroupEventHandler) {
+	c.groupEventHandler = handler
+}
+
+// splitGroupInfo turns one GroupInfo notification into an event per change,
+// since a single notification may e.g. add and promote users at once.
+func splitGroupInfo(evt *events.GroupInfo) []*GroupEvent {
+	base := GroupEvent{
+		Group:     evt.JID,
+		Reason:    evt.JoinReason,
+		Timestamp: evt.Timestamp,
+		Raw:       evt,
+	}
+	if evt.SenderPN != nil {
+		base.Actor = *evt.SenderPN
+	} else if evt.Sender != nil {
+		base.Actor = *evt.Sender
+	}
+
+	var result []*GroupEvent
+	add := func(eventType string, users []types.JID) {
+		e := base
+		e.Type = eventType
+		e.Users = users
+		result = append(result, &e)
+	}
+
+	if len(evt.Join) > 0 {
+		add(GroupEventJoin, evt.Join)
+	}
+	if len(evt.Leave) > 0 {
+		add(GroupEventLeave, evt.Leave)
+	}
+	if len(evt.Promote) > 0 {
+		add(GroupEventPromote, evt.Promote)
+	}
+	if len(evt.Demote) > 0 {
+		add(GroupEventDemote, evt.Demote)
+	}
+	if evt.Name != nil {
+		add(GroupEventSubject, nil)
+		result[len(result)-1].Subject = evt.Name.Name
+	}
+	if evt.Topic != nil {
+		add(GroupEventDescription, nil)
+		result[len(result)-1].Subject = evt.Topic.Topic
+	}
+
+	return result
+}
+
+func (c *Client) handleGroupInfo(evt *events.GroupInfo) {
+	c.groups.invalidate(evt.JID)
+
+	if c.groupEventHandler == nil {
+		return
+	}
+
+	for _, groupEvent := range splitGroupInfo(evt) {
+		logger.Info("👥 Group %s: %s %d user(s)", groupEvent.Group.User, groupEvent.Type, len(groupEvent.Users))
+		if err := c.groupEventHandler(groupEvent); err != nil {
+			logger.Error("Failed to handle group event: %v", err)
+		}
+	}
+}
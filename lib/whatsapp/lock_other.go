@@ -0,0 +1,13 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd)
+
+package whatsapp
+
+// storeLock is a no-op where flock isn't available; running two processes
+// on one session store is then up to the user to avoid.
+type storeLock struct{}
+
+func lockStore(dir string) (*storeLock, error) {
+	return &storeLock{}, nil
+}
+
+func (l *storeLock) unlock() {}
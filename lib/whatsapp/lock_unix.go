@@ -0,0 +1,41 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd
+
+package whatsapp
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// storeLock keeps a session store from being opened by two processes, e.g.
+// the bot and a backup, at the same time. The lock goes away with the
+// process, so a crash never leaves a stale lock behind.
+type storeLock struct {
+	file *os.File
+}
+
+func lockStore(dir string) (*storeLock, error) {
+	file, err := os.OpenFile(filepath.Join(dir, "session.lock"), os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		file.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, ErrStoreInUse
+		}
+		return nil, err
+	}
+	return &storeLock{file: file}, nil
+}
+
+func (l *storeLock) unlock() {
+	if l == nil {
+		return
+	}
+	syscall.Flock(int(l.file.Fd()), syscall.LOCK_UN)
+	l.file.Close()
+}
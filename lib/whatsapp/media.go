@@ -0,0 +1,103 @@
+package whatsapp
+
+import (
+	"context"
+	"fmt"
+
+	"yukii-bot/lib/logger"
+	"yukii-bot/lib/media"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"google.golang.org/protobuf/proto"
+)
+
+// MediaMessage returns the message carrying downloadable media, preferring
+// the message itself over the one it quotes.
+func (m *Message) MediaMessage() *waE2E.Message {
+	if m.Raw != nil && hasMedia(m.Raw.Message) {
+		return m.Raw.Message
+	}
+	if hasMedia(m.QuotedMessage) {
+		return m.QuotedMessage
+	}
+	return nil
+}
+
+func hasMedia(m *waE2E.Message) bool {
+	if m == nil {
+		return false
+	}
+	return m.ImageMessage != nil || m.VideoMessage != nil || m.StickerMessage != nil ||
+		m.DocumentMessage != nil || m.AudioMessage != nil
+}
+
+func (c *Client) DownloadMedia(m *waE2E.Message) ([]byte, string, error) {
+	var downloadable whatsmeow.DownloadableMessage
+	var mimetype string
+
+	switch {
+	case m == nil:
+		return nil, "", fmt.Errorf("message has no media")
+	case m.ImageMessage != nil:
+		downloadable, mimetype = m.ImageMessage, m.ImageMessage.GetMimetype()
+	case m.VideoMessage != nil:
+		downloadable, mimetype = m.VideoMessage, m.VideoMessage.GetMimetype()
+	case m.StickerMessage != nil:
+		downloadable, mimetype = m.StickerMessage, m.StickerMessage.GetMimetype()
+	case m.DocumentMessage != nil:
+		downloadable, mimetype = m.DocumentMessage, m.DocumentMessage.GetMimetype()
+	case m.AudioMessage != nil:
+		downloadable, mimetype = m.AudioMessage, m.AudioMessage.GetMimetype()
+	default:
+		return nil, "", fmt.Errorf("message has no media")
+	}
+
+	data, err := c.client().Download(context.Background(), downloadable)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return data, mimetype, nil
+}
+
+func (c *Client) SendSticker(to types.JID, sticker *media.Sticker, quoted *Message) error {
+	uploaded, err := c.client().Upload(context.Background(), sticker.Data, whatsmeow.MediaImage)
+	if err != nil {
+		return fmt.Errorf("failed to upload sticker: %v", err)
+	}
+
+	stickerMsg := &waE2E.StickerMessage{
+		URL:           proto.String(uploaded.URL),
+		DirectPath:    proto.String(uploaded.DirectPath),
+		MediaKey:      uploaded.MediaKey,
+		FileEncSHA256: uploaded.FileEncSHA256,
+		FileSHA256:    uploaded.FileSHA256,
+		FileLength:    proto.Uint64(uploaded.FileLength),
+		Mimetype:      proto.String("image/webp"),
+		Width:         proto.Uint32(uint32(sticker.Width)),
+		Height:        proto.Uint32(uint32(sticker.Height)),
+		IsAnimated:    proto.Bool(sticker.Animated),
+	}
+	// Messages the bot built itself have no ID and nothing to quote.
+	if quoted != nil && quoted.ID != "" {
+		stickerMsg.ContextInfo = &waE2E.ContextInfo{
+			StanzaID:    proto.String(quoted.ID),
+			Participant: proto.String(quoted.Sender.String()),
+		}
+		if quoted.Raw != nil {
+			stickerMsg.ContextInfo.QuotedMessage = quoted.Raw.Message
+		}
+	}
+
+	_, err = c.send(to, &waE2E.Message{StickerMessage: stickerMsg})
+	if err != nil {
+		return err
+	}
+
+	recipient := c.getDisplayName(to)
+	logger.MessageOut(recipient, "sticker", "[Sticker]", to.String())
+
+	return nil
+}
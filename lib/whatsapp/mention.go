@@ -0,0 +1,198 @@
+package whatsapp
+
+import (
+	"regexp"
+	"strings"
+
+	"yukii-bot/lib/logger"
+
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"google.golang.org/protobuf/proto"
+)
+
+var mentionArgRegex = regexp.MustCompile(`^@?\+?([0-9][0-9 \-]{4,})$`)
+
+// ParseMention resolves a single command argument such as "@628123456789",
+// "+62 812-3456-789" or a full JID into a user JID.
+func ParseMention(arg string) (types.JID, bool) {
+	arg = strings.TrimSpace(arg)
+	if arg == "" {
+		return types.JID{}, false
+	}
+
+	if strings.Contains(arg, "@") && !strings.HasPrefix(arg, "@") {
+		jid, err := types.ParseJID(arg)
+		if err != nil || jid.User == "" {
+			return types.JID{}, false
+		}
+		return jid, true
+	}
+
+	matches := mentionArgRegex.FindStringSubmatch(arg)
+	if len(matches) < 2 {
+		return types.JID{}, false
+	}
+
+	number := strings.NewReplacer(" ", "", "-", "").Replace(matches[1])
+	return types.NewJID(number, types.DefaultUserServer), true
+}
+
+// ResolveMention resolves a single argument like ParseMention. An argument
+// the sender tagged through WhatsApp's mention picker is matched against the
+// message's Mentions first, so LID mentions resolve to the JID WhatsApp sent.
+func ResolveMention(msg *Message, arg string) (types.JID, bool) {
+	arg = strings.TrimSpace(arg)
+	if strings.HasPrefix(arg, "@") && msg != nil {
+		user := strings.TrimPrefix(arg, "@")
+		for _, mention := range msg.Mentions {
+			if mention.User == user {
+				return mention.ToNonAD(), true
+			}
+		}
+	}
+
+	jid, ok := ParseMention(arg)
+	if !ok {
+		return types.JID{}, false
+	}
+	return jid.ToNonAD(), true
+}
+
+// ResolveMentions returns the JIDs referenced by args, see ResolveMention.
+func ResolveMentions(msg *Message, args []string) []types.JID {
+	var result []types.JID
+	seen := make(map[string]bool)
+
+	for _, arg := range args {
+		jid, ok := ResolveMention(msg, arg)
+		if !ok || seen[jid.String()] {
+			continue
+		}
+		seen[jid.String()] = true
+		result = append(result, jid)
+	}
+
+	return result
+}
+
+// MentionText renders the "@number" token WhatsApp expects in the message
+// body for a mention notification to fire.
+func MentionText(jid types.JID) string {
+	return "@" + jid.User
+}
+
+func renderMentions(text string, mentions []types.JID) (string, []string) {
+	jids := make([]string, 0, len(mentions))
+	for _, jid := range mentions {
+		jid = jid.ToNonAD()
+		tag := MentionText(jid)
+		if !containsTag(text, tag) {
+			if text != "" {
+				text += " "
+			}
+			text += tag
+		}
+		jids = append(jids, jid.String())
+	}
+	return text, jids
+}
+
+// containsTag reports whether text has tag as a whole token, so "@123"
+// isn't found in "@1234".
+func containsTag(text, tag string) bool {
+	for {
+		i := strings.Index(text, tag)
+		if i < 0 {
+			return false
+		}
+		text = text[i+len(tag):]
+		if text == "" || text[0] < '0' || text[0] > '9' {
+			return true
+		}
+	}
+}
+
+func parseMentions(info *waE2E.ContextInfo) []types.JID {
+	var mentions []types.JID
+	for _, raw := range info.GetMentionedJID() {
+		jid, err := types.ParseJID(raw)
+		if err != nil {
+			continue
+		}
+		mentions = append(mentions, jid)
+	}
+	return mentions
+}
+
+func getContextInfo(m *waE2E.Message) *waE2E.ContextInfo {
+	switch {
+	case m == nil:
+		return nil
+	case m.ExtendedTextMessage != nil:
+		return m.ExtendedTextMessage.GetContextInfo()
+	case m.ImageMessage != nil:
+		return m.ImageMessage.GetContextInfo()
+	case m.VideoMessage != nil:
+		return m.VideoMessage.GetContextInfo()
+	case m.AudioMessage != nil:
+		return m.AudioMessage.GetContextInfo()
+	case m.DocumentMessage != nil:
+		return m.DocumentMessage.GetContextInfo()
+	case m.StickerMessage != nil:
+		return m.StickerMessage.GetContextInfo()
+	case m.LocationMessage != nil:
+		return m.LocationMessage.GetContextInfo()
+	case m.ContactMessage != nil:
+		return m.ContactMessage.GetContextInfo()
+	}
+	return nil
+}
+
+func (c *Client) SendMessageWithMentions(to types.JID, text string, mentions []types.JID) error {
+	text, jids := renderMentions(text, mentions)
+
+	msg := &waE2E.Message{
+		ExtendedTextMessage: &waE2E.ExtendedTextMessage{
+			Text: proto.String(text),
+			ContextInfo: &waE2E.ContextInfo{
+				MentionedJID: jids,
+			},
+		},
+	}
+
+	_, err := c.send(to, msg)
+	if err != nil {
+		return err
+	}
+
+	recipient := c.getDisplayName(to)
+	logger.MessageOut(recipient, "text", text, to.String())
+
+	return nil
+}
+
+func (c *Client) SendReplyWithMentions(original *Message, text string, mentions []types.JID) error {
+	text, jids := renderMentions(text, mentions)
+
+	msg := &waE2E.Message{
+		ExtendedTextMessage: &waE2E.ExtendedTextMessage{
+			Text: proto.String(text),
+			ContextInfo: &waE2E.ContextInfo{
+				StanzaID:     proto.String(original.ID),
+				Participant:  proto.String(original.Sender.String()),
+				MentionedJID: jids,
+			},
+		},
+	}
+
+	_, err := c.sendWithPriority(original.From, msg, PriorityHigh)
+	if err != nil {
+		return err
+	}
+
+	recipient := c.getDisplayName(original.From)
+	logger.MessageOut(recipient, "reply", text, original.From.String())
+
+	return nil
+}
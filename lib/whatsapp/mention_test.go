@@ -0,0 +1,44 @@
+package whatsapp
+
+import (
+	"testing"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+func TestResolveMentionPrefersTaggedJID(t *testing.T) {
+	lid := types.NewJID("123456789012345", types.HiddenUserServer)
+	msg := &Message{Mentions: []types.JID{lid}}
+
+	jid, ok := ResolveMention(msg, "@123456789012345")
+	if !ok || jid != lid {
+		t.Errorf("tagged mention = %v, %v, want %v", jid, ok, lid)
+	}
+
+	jid, ok = ResolveMention(msg, "@6281234567890")
+	if want := types.NewJID("6281234567890", types.DefaultUserServer); !ok || jid != want {
+		t.Errorf("typed number = %v, %v, want %v", jid, ok, want)
+	}
+}
+
+func TestRenderMentionsMatchesWholeTags(t *testing.T) {
+	short := types.NewJID("123", types.DefaultUserServer)
+	long := types.NewJID("1234", types.DefaultUserServer)
+
+	tests := []struct {
+		text     string
+		mentions []types.JID
+		want     string
+	}{
+		{"hi @123", []types.JID{short}, "hi @123"},
+		{"hi @123!", []types.JID{short}, "hi @123!"},
+		{"hi @1234", []types.JID{short}, "hi @1234 @123"},
+		{"hi @1234 and @123", []types.JID{short, long}, "hi @1234 and @123"},
+		{"", []types.JID{short}, "@123"},
+	}
+	for _, tt := range tests {
+		if got, _ := renderMentions(tt.text, tt.mentions); got != tt.want {
+			t.Errorf("renderMentions(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
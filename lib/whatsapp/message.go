@@ -0,0 +1,302 @@
+package whatsapp
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/tidwall/gjson"
+	"go.mau.fi/whatsmeow/proto/waCommon"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+type MessageRef struct {
+	ID     string
+	Chat   types.JID
+	Sender types.JID
+	FromMe bool
+}
+
+type Reaction struct {
+	Target  MessageRef
+	Emoji   string
+	Removed bool
+}
+
+type Poll struct {
+	Question        string
+	Options         []string
+	SelectableCount int
+}
+
+type PollVote struct {
+	Poll           MessageRef
+	Voter          types.JID
+	SelectedHashes [][]byte
+	Decrypted      bool
+}
+
+type Edit struct {
+	Target  MessageRef
+	Body    string
+	Message *waE2E.Message
+}
+
+type Location struct {
+	Latitude  float64
+	Longitude float64
+	Name      string
+	Address   string
+	Caption   string
+	IsLive    bool
+}
+
+type Contact struct {
+	DisplayName string
+	VCard       string
+}
+
+type Selection struct {
+	ID   string
+	Text string
+}
+
+// unwrapMessage strips ephemeral, view-once and document-with-caption
+// envelopes. Incoming events are already unwrapped by whatsmeow, but quoted
+// messages inside ContextInfo are not.
+func unwrapMessage(m *waE2E.Message) (msg *waE2E.Message, ephemeral, viewOnce bool) {
+	msg = m
+	for msg != nil {
+		switch {
+		case msg.GetDeviceSentMessage().GetMessage() != nil:
+			msg = msg.GetDeviceSentMessage().GetMessage()
+		case msg.GetEphemeralMessage().GetMessage() != nil:
+			msg = msg.GetEphemeralMessage().GetMessage()
+			ephemeral = true
+		case msg.GetViewOnceMessage().GetMessage() != nil:
+			msg = msg.GetViewOnceMessage().GetMessage()
+			viewOnce = true
+		case msg.GetViewOnceMessageV2().GetMessage() != nil:
+			msg = msg.GetViewOnceMessageV2().GetMessage()
+			viewOnce = true
+		case msg.GetViewOnceMessageV2Extension().GetMessage() != nil:
+			msg = msg.GetViewOnceMessageV2Extension().GetMessage()
+			viewOnce = true
+		case msg.GetDocumentWithCaptionMessage().GetMessage() != nil:
+			msg = msg.GetDocumentWithCaptionMessage().GetMessage()
+		case msg.GetEditedMessage().GetMessage() != nil:
+			msg = msg.GetEditedMessage().GetMessage()
+		default:
+			return msg, ephemeral, viewOnce
+		}
+	}
+	return msg, ephemeral, viewOnce
+}
+
+// messageRef resolves a key written from the point of view of evt's sender
+// into a reference from the bot's point of view.
+func (c *Client) messageRef(key *waCommon.MessageKey, evt *events.Message) MessageRef {
+	ref := MessageRef{
+		ID:   key.GetID(),
+		Chat: evt.Info.Chat,
+	}
+
+	switch {
+	case key.GetFromMe():
+		ref.Sender = evt.Info.Sender
+		ref.FromMe = evt.Info.IsFromMe
+	case key.GetParticipant() != "":
+		if jid, err := types.ParseJID(key.GetParticipant()); err == nil {
+			ref.Sender = jid
+			ref.FromMe = c.isOwnJID(jid)
+		}
+	case evt.Info.IsFromMe:
+		ref.Sender = evt.Info.Chat
+	default:
+		ref.Sender = c.GetJID().ToNonAD()
+		ref.FromMe = true
+	}
+
+	return ref
+}
+
+func (c *Client) isOwnJID(jid types.JID) bool {
+	if c.client().Store.ID == nil {
+		return false
+	}
+	return jid.User == c.client().Store.ID.User || jid.User == c.client().Store.LID.User
+}
+
+func textOf(m *waE2E.Message) string {
+	switch {
+	case m == nil:
+		return ""
+	case m.Conversation != nil:
+		return m.GetConversation()
+	case m.ExtendedTextMessage != nil:
+		return m.GetExtendedTextMessage().GetText()
+	case m.ImageMessage != nil:
+		return m.GetImageMessage().GetCaption()
+	case m.VideoMessage != nil:
+		return m.GetVideoMessage().GetCaption()
+	case m.DocumentMessage != nil:
+		return m.GetDocumentMessage().GetCaption()
+	}
+	return ""
+}
+
+func pollOf(m *waE2E.Message) *waE2E.PollCreationMessage {
+	switch {
+	case m.PollCreationMessage != nil:
+		return m.PollCreationMessage
+	case m.PollCreationMessageV2 != nil:
+		return m.PollCreationMessageV2
+	case m.PollCreationMessageV3 != nil:
+		return m.PollCreationMessageV3
+	}
+	return nil
+}
+
+// phoneJID returns the phone number JID of a user where it's known, from
+// alt or the LID store. The same user can write from their LID and from their
+// number, and this keeps them to one JID.
+func (c *Client) phoneJID(jid, alt types.JID) types.JID {
+	jid = jid.ToNonAD()
+	if jid.Server != types.HiddenUserServer {
+		return jid
+	}
+	if alt.Server == types.DefaultUserServer {
+		return alt.ToNonAD()
+	}
+	if pn, err := c.client().Store.LIDs.GetPNForLID(context.Background(), jid); err == nil && !pn.IsEmpty() {
+		return pn.ToNonAD()
+	}
+	return jid
+}
+
+// PhoneJID maps a LID to the user's phone number JID where it's known. Other
+// JIDs are returned without their device.
+func (c *Client) PhoneJID(jid types.JID) types.JID {
+	return c.phoneJID(jid, types.EmptyJID)
+}
+
+// SenderPhoneJID is the phone number JID of the sender of msg where it's
+// known, for counting a user's messages under one key.
+func (c *Client) SenderPhoneJID(msg *Message) types.JID {
+	return c.phoneJID(msg.Sender, msg.SenderAlt)
+}
+
+// convertSpecial recognizes the message types beyond plain text and media.
+// It returns false when the message is none of them.
+func (c *Client) convertSpecial(msg *Message, evt *events.Message, m *waE2E.Message) bool {
+	switch {
+	case pollOf(m) != nil:
+		poll := pollOf(m)
+		msg.Poll = &Poll{
+			Question:        poll.GetName(),
+			SelectableCount: int(poll.GetSelectableOptionsCount()),
+		}
+		for _, option := range poll.GetOptions() {
+			msg.Poll.Options = append(msg.Poll.Options, option.GetOptionName())
+		}
+		msg.Body = poll.GetName()
+		msg.Type = "poll"
+	case m.PollUpdateMessage != nil:
+		msg.PollVote = &PollVote{
+			Poll:  c.messageRef(m.PollUpdateMessage.GetPollCreationMessageKey(), evt),
+			Voter: c.phoneJID(evt.Info.Sender, evt.Info.SenderAlt),
+		}
+		c.decryptPollVote(evt, msg.PollVote)
+		msg.Body = "[Poll Vote]"
+		msg.Type = "poll_vote"
+	case m.ReactionMessage != nil:
+		reaction := m.ReactionMessage
+		msg.Reaction = &Reaction{
+			Target:  c.messageRef(reaction.GetKey(), evt),
+			Emoji:   reaction.GetText(),
+			Removed: reaction.GetText() == "",
+		}
+		msg.Body = reaction.GetText()
+		msg.Type = "reaction"
+	case m.ProtocolMessage != nil:
+		protocol := m.ProtocolMessage
+		switch protocol.GetType() {
+		case waE2E.ProtocolMessage_MESSAGE_EDIT:
+			edited, _, _ := unwrapMessage(protocol.GetEditedMessage())
+			msg.Edit = &Edit{
+				Target:  c.messageRef(protocol.GetKey(), evt),
+				Body:    textOf(edited),
+				Message: edited,
+			}
+			msg.Body = msg.Edit.Body
+			msg.Type = "edit"
+		case waE2E.ProtocolMessage_REVOKE:
+			ref := c.messageRef(protocol.GetKey(), evt)
+			msg.Revoked = &ref
+			msg.Body = "[Deleted Message]"
+			msg.Type = "revoke"
+		default:
+			msg.Body = fmt.Sprintf("[Protocol: %s]", protocol.GetType())
+			msg.Type = "protocol"
+		}
+	case m.ButtonsResponseMessage != nil:
+		msg.Selection = &Selection{
+			ID:   m.ButtonsResponseMessage.GetSelectedButtonID(),
+			Text: m.ButtonsResponseMessage.GetSelectedDisplayText(),
+		}
+		msg.Body = msg.Selection.Text
+		msg.Type = "button_response"
+	case m.TemplateButtonReplyMessage != nil:
+		msg.Selection = &Selection{
+			ID:   m.TemplateButtonReplyMessage.GetSelectedID(),
+			Text: m.TemplateButtonReplyMessage.GetSelectedDisplayText(),
+		}
+		msg.Body = msg.Selection.Text
+		msg.Type = "button_response"
+	case m.ListResponseMessage != nil:
+		msg.Selection = &Selection{
+			ID:   m.ListResponseMessage.GetSingleSelectReply().GetSelectedRowID(),
+			Text: m.ListResponseMessage.GetTitle(),
+		}
+		msg.Body = msg.Selection.Text
+		msg.Type = "list_response"
+	case m.InteractiveResponseMessage != nil:
+		params := m.InteractiveResponseMessage.GetNativeFlowResponseMessage().GetParamsJSON()
+		msg.Selection = &Selection{
+			ID:   gjson.Get(params, "id").String(),
+			Text: m.InteractiveResponseMessage.GetBody().GetText(),
+		}
+		msg.Body = msg.Selection.Text
+		msg.Type = "interactive_response"
+	case m.LiveLocationMessage != nil:
+		live := m.LiveLocationMessage
+		msg.Location = &Location{
+			Latitude:  live.GetDegreesLatitude(),
+			Longitude: live.GetDegreesLongitude(),
+			Caption:   live.GetCaption(),
+			IsLive:    true,
+		}
+		msg.Body = "[Live Location]"
+		if live.GetCaption() != "" {
+			msg.Body = live.GetCaption()
+		}
+		msg.Type = "live_location"
+	case m.ContactsArrayMessage != nil:
+		for _, contact := range m.ContactsArrayMessage.GetContacts() {
+			msg.Contacts = append(msg.Contacts, Contact{
+				DisplayName: contact.GetDisplayName(),
+				VCard:       contact.GetVcard(),
+			})
+		}
+		msg.Body = "[Contacts]"
+		if name := m.ContactsArrayMessage.GetDisplayName(); name != "" {
+			msg.Body = name
+		}
+		msg.Type = "contacts"
+	default:
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,31 @@
+package whatsapp
+
+import (
+	"testing"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+func TestSenderPhoneJID(t *testing.T) {
+	c := &Client{}
+	phone := types.NewJID("6281234567890", types.DefaultUserServer)
+	lid := types.NewJID("123456789012345", types.HiddenUserServer)
+
+	tests := []struct {
+		name string
+		msg  *Message
+	}{
+		{"phone", &Message{Sender: phone}},
+		{"device", &Message{Sender: types.NewADJID(phone.User, 0, 12)}},
+		{"lid with phone", &Message{Sender: lid, SenderAlt: phone}},
+		{"lid device with phone", &Message{
+			Sender:    types.JID{User: lid.User, Device: 3, Server: types.HiddenUserServer},
+			SenderAlt: types.NewADJID(phone.User, 0, 3),
+		}},
+	}
+	for _, tt := range tests {
+		if got := c.SenderPhoneJID(tt.msg); got != phone {
+			t.Errorf("%s: %v, want %v", tt.name, got, phone)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package whatsapp
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"yukii-bot/lib/config"
+	"yukii-bot/lib/logger"
+
+	"go.mau.fi/whatsmeow/store/sqlstore"
+)
+
+// Tables other tables reference, copied first so foreign keys hold.
+var storeTableOrder = map[string]int{
+	"whatsmeow_device":            0,
+	"whatsmeow_app_state_version": 1,
+}
+
+// MigrateStore copies the SQLite session store of an account into the
+// configured Postgres store. Both are upgraded to the same schema by
+// sqlstore first; the target must not hold a device yet. The SQLite file
+// is left in place.
+func MigrateStore(ctx context.Context, cfg *config.Config, session config.Session) error {
+	driver, dsn, err := cfg.SessionStore(session)
+	if err != nil {
+		return err
+	}
+	if driver == "sqlite3" {
+		return fmt.Errorf("set store_driver to postgres to migrate the session store")
+	}
+
+	source := cfg.SessionStoreFile(session.Name)
+	if _, err := os.Stat(source); err != nil {
+		return fmt.Errorf("no SQLite session store for %s: %w", session.Name, err)
+	}
+
+	lock, err := lockStore(cfg.SessionDir(session.Name))
+	if err != nil {
+		return err
+	}
+	defer lock.unlock()
+
+	src, err := openUpgraded(ctx, "sqlite3", fmt.Sprintf("file:%s?_foreign_keys=on", source))
+	if err != nil {
+		return fmt.Errorf("source store: %w", err)
+	}
+	defer src.Close()
+
+	dst, err := openUpgraded(ctx, driver, dsn)
+	if err != nil {
+		return fmt.Errorf("target store: %w", err)
+	}
+	defer dst.Close()
+
+	var devices int
+	if err := dst.QueryRowContext(ctx, "SELECT COUNT(*) FROM whatsmeow_device").Scan(&devices); err != nil {
+		return err
+	}
+	if devices > 0 {
+		return fmt.Errorf("target store already holds a session")
+	}
+
+	tables, err := storeTables(ctx, src)
+	if err != nil {
+		return err
+	}
+	booleans, err := booleanColumns(ctx, dst)
+	if err != nil {
+		return err
+	}
+
+	tx, err := dst.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, table := range tables {
+		count, err := copyTable(ctx, src, tx, table, booleans[table])
+		if err != nil {
+			return fmt.Errorf("failed to copy %s: %w", table, err)
+		}
+		logger.Info("📦 %s: %d rows", table, count)
+	}
+	return tx.Commit()
+}
+
+// openUpgraded opens a store database with its tables created or upgraded
+// by sqlstore.
+func openUpgraded(ctx context.Context, driver, dsn string) (*sql.DB, error) {
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := sqlstore.NewWithDB(db, driver, nil).Upgrade(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+func storeTables(ctx context.Context, db *sql.DB) ([]string, error) {
+	rows, err := db.QueryContext(ctx,
+		"SELECT name FROM sqlite_master WHERE type = 'table' AND name LIKE 'whatsmeow\\_%' ESCAPE '\\' AND name <> 'whatsmeow_version' ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tables []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		tables = append(tables, name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(tables, func(i, j int) bool {
+		return tableRank(tables[i]) < tableRank(tables[j])
+	})
+	return tables, nil
+}
+
+func tableRank(table string) int {
+	if rank, ok := storeTableOrder[table]; ok {
+		return rank
+	}
+	return len(storeTableOrder)
+}
+
+// booleanColumns lists the boolean columns per table, since SQLite may
+// hand them out as integers.
+func booleanColumns(ctx context.Context, db *sql.DB) (map[string]map[string]bool, error) {
+	rows, err := db.QueryContext(ctx,
+		"SELECT table_name, column_name FROM information_schema.columns WHERE table_schema = current_schema() AND data_type = 'boolean'")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make(map[string]map[string]bool)
+	for rows.Next() {
+		var table, column string
+		if err := rows.Scan(&table, &column); err != nil {
+			return nil, err
+		}
+		if result[table] == nil {
+			result[table] = make(map[string]bool)
+		}
+		result[table][column] = true
+	}
+	return result, rows.Err()
+}
+
+func copyTable(ctx context.Context, src *sql.DB, dst *sql.Tx, table string, booleans map[string]bool) (int, error) {
+	rows, err := src.QueryContext(ctx, fmt.Sprintf("SELECT * FROM %s", quoteIdent(table)))
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+	quoted := make([]string, len(columns))
+	params := make([]string, len(columns))
+	for i, column := range columns {
+		quoted[i] = quoteIdent(column)
+		params[i] = fmt.Sprintf("$%d", i+1)
+	}
+	stmt, err := dst.PrepareContext(ctx, fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		quoteIdent(table), strings.Join(quoted, ", "), strings.Join(params, ", ")))
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	values := make([]interface{}, len(columns))
+	pointers := make([]interface{}, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+
+	count := 0
+	for rows.Next() {
+		if err := rows.Scan(pointers...); err != nil {
+			return count, err
+		}
+		for i, column := range columns {
+			if n, ok := values[i].(int64); ok && booleans[column] {
+				values[i] = n != 0
+			}
+		}
+		if _, err := stmt.ExecContext(ctx, values...); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, rows.Err()
+}
+
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
@@ -0,0 +1,69 @@
+package whatsapp
+
+import (
+	"errors"
+	"time"
+
+	"yukii-bot/lib/logger"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+)
+
+// outboxMaxAge drops queued messages that would arrive too late to make
+// sense, e.g. replies to commands sent hours ago.
+const outboxMaxAge = time.Hour
+
+type queuedMessage struct {
+	to     types.JID
+	msg    *waE2E.Message
+	id     types.MessageID
+	queued time.Time
+}
+
+func (c *Client) enqueue(to types.JID, msg *waE2E.Message, id types.MessageID) {
+	c.outboxMu.Lock()
+	defer c.outboxMu.Unlock()
+
+	limit := c.config.WhatsApp.OfflineQueueSize
+	if limit > 0 && len(c.outbox) >= limit {
+		logger.Warning("📭 Offline queue is full, dropping the oldest message")
+		c.outbox = c.outbox[1:]
+	}
+	c.outbox = append(c.outbox, queuedMessage{to: to, msg: msg, id: id, queued: time.Now()})
+
+	logger.Info("📬 Offline, queued message to %s (%d queued)", to.User, len(c.outbox))
+}
+
+// flushOutbox sends the queued messages in order. It stops at the first
+// message that can't be sent because the connection dropped again.
+func (c *Client) flushOutbox() {
+	c.outboxMu.Lock()
+	queued := c.outbox
+	c.outbox = nil
+	c.outboxMu.Unlock()
+
+	if len(queued) == 0 {
+		return
+	}
+	logger.Info("📤 Sending %d queued message(s)", len(queued))
+
+	for i, item := range queued {
+		if time.Since(item.queued) > outboxMaxAge {
+			logger.Warning("📭 Dropping queued message to %s, it is too old", item.to.User)
+			continue
+		}
+
+		err := c.queue.submit(item.to, item.msg, item.id, PriorityNormal, true)
+		if errors.Is(err, whatsmeow.ErrNotConnected) {
+			c.outboxMu.Lock()
+			c.outbox = append(queued[i:], c.outbox...)
+			c.outboxMu.Unlock()
+			return
+		}
+		if err != nil {
+			logger.Error("Failed to send queued message to %s: %v", item.to.User, err)
+		}
+	}
+}
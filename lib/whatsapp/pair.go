@@ -0,0 +1,238 @@
+package whatsapp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"yukii-bot/lib/logger"
+
+	"go.mau.fi/whatsmeow"
+)
+
+type PairState int
+
+const (
+	PairConnecting PairState = iota
+	PairCodeIssued
+	PairLinked
+	PairFailed
+)
+
+func (s PairState) String() string {
+	switch s {
+	case PairConnecting:
+		return "connecting"
+	case PairCodeIssued:
+		return "code issued"
+	case PairLinked:
+		return "linked"
+	default:
+		return "failed"
+	}
+}
+
+// pairCodeLifetime is how long the login socket stays open for one code:
+// WhatsApp closes it once the QR codes that are issued alongside run out.
+const pairCodeLifetime = 160 * time.Second
+
+// PairUpdate reports the progress of a pairing. Code and Expires are set
+// when State is PairCodeIssued, Err when it is PairFailed.
+type PairUpdate struct {
+	State   PairState
+	Code    string
+	Expires time.Time
+	Attempt int
+	Err     error
+}
+
+type PairHandler func(PairUpdate)
+
+// SetPairHandler registers a function receiving every pairing update, so
+// e.g. chat commands can show the code instead of the terminal.
+func (c *Client) SetPairHandler(handler PairHandler) {
+	c.pairHandler = handler
+}
+
+// NormalizePhone turns a phone number as people write it, like
+// +62 812-3456-7890 or 0062..., into the international digits WhatsApp
+// expects.
+func NormalizePhone(phone string) (string, error) {
+	phone = strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '(', ')', '.':
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(phone))
+	phone = strings.TrimPrefix(phone, "+")
+	if strings.HasPrefix(phone, "00") {
+		phone = phone[2:]
+	}
+
+	if phone == "" {
+		return "", fmt.Errorf("phone number is required")
+	}
+	for _, r := range phone {
+		if r < '0' || r > '9' {
+			return "", fmt.Errorf("invalid phone number %q", phone)
+		}
+	}
+	if phone[0] == '0' {
+		return "", fmt.Errorf("phone number %s must start with the country code", phone)
+	}
+	if len(phone) < 8 || len(phone) > 15 {
+		return "", fmt.Errorf("phone number %s must have 8 to 15 digits", phone)
+	}
+	return phone, nil
+}
+
+// StartPairing links this client to the phone with a pairing code and
+// reports every step on the returned channel, which is closed when the
+// pairing is done. Codes are renewed until pair_timeout runs out.
+func (c *Client) StartPairing(ctx context.Context, phone string) (<-chan PairUpdate, error) {
+	phone, err := NormalizePhone(phone)
+	if err != nil {
+		return nil, err
+	}
+	if c.client().Store.ID != nil {
+		return nil, fmt.Errorf("already logged in")
+	}
+
+	timeout := time.Duration(c.config.WhatsApp.PairTimeout) * time.Second
+	if timeout <= 0 {
+		timeout = 5 * time.Minute
+	}
+
+	updates := make(chan PairUpdate, 8)
+	go func() {
+		defer close(updates)
+
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		emit := func(update PairUpdate) {
+			if c.pairHandler != nil {
+				c.pairHandler(update)
+			}
+			updates <- update
+		}
+
+		for attempt := 1; ; attempt++ {
+			done, err := c.pairAttempt(ctx, phone, attempt, emit)
+			if done {
+				emit(PairUpdate{State: PairLinked, Attempt: attempt})
+				return
+			}
+			if err == nil && ctx.Err() != nil {
+				err = fmt.Errorf("pairing timed out after %v", timeout)
+			}
+			if err != nil {
+				c.client().Disconnect()
+				emit(PairUpdate{State: PairFailed, Attempt: attempt, Err: err})
+				return
+			}
+			logger.Info("🔄 Pairing code expired, requesting a new one")
+		}
+	}()
+
+	return updates, nil
+}
+
+// pairAttempt runs one login socket: it requests a code and waits for the
+// phone to link. The QR channel carries the PairSuccess and PairError
+// events. It returns false without error when the code expired.
+func (c *Client) pairAttempt(ctx context.Context, phone string, attempt int, emit func(PairUpdate)) (bool, error) {
+	emit(PairUpdate{State: PairConnecting, Attempt: attempt})
+
+	c.client().Disconnect()
+	qrChan, err := c.client().GetQRChannel(ctx)
+	if err != nil {
+		return false, err
+	}
+	if err := c.client().Connect(); err != nil {
+		return false, err
+	}
+
+	// The first QR code means the login socket is ready for PairPhone.
+	select {
+	case <-ctx.Done():
+		return false, nil
+	case item, ok := <-qrChan:
+		if !ok || item.Event != whatsmeow.QRChannelEventCode {
+			return false, pairEventError(item)
+		}
+	}
+
+	code, err := c.client().PairPhone(ctx, phone, true, whatsmeow.PairClientChrome, "Chrome (Linux)")
+	if err != nil {
+		if ctx.Err() != nil {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to request pairing code: %w", err)
+	}
+	emit(PairUpdate{State: PairCodeIssued, Code: code, Expires: time.Now().Add(pairCodeLifetime), Attempt: attempt})
+
+	for {
+		select {
+		case <-ctx.Done():
+			return false, nil
+		case item, ok := <-qrChan:
+			if !ok {
+				return false, nil
+			}
+			switch item.Event {
+			case whatsmeow.QRChannelEventCode:
+				// Only refreshes the unused QR code.
+			case whatsmeow.QRChannelSuccess.Event:
+				return true, nil
+			case whatsmeow.QRChannelTimeout.Event:
+				return false, nil
+			default:
+				return false, pairEventError(item)
+			}
+		}
+	}
+}
+
+func pairEventError(item whatsmeow.QRChannelItem) error {
+	switch {
+	case item.Event == "":
+		return errors.New("login socket closed unexpectedly")
+	case item.Error != nil:
+		return fmt.Errorf("pairing failed: %w", item.Error)
+	default:
+		return fmt.Errorf("pairing failed: %s", item.Event)
+	}
+}
+
+// loginPair is the terminal frontend of StartPairing.
+func (c *Client) loginPair() error {
+	if c.pairCode == "" {
+		return fmt.Errorf("phone number for the pairing code is required")
+	}
+
+	updates, err := c.StartPairing(context.Background(), c.pairCode)
+	if err != nil {
+		return err
+	}
+
+	for update := range updates {
+		switch update.State {
+		case PairConnecting:
+			logger.Info("🔄 Connecting for pairing (attempt %d)...", update.Attempt)
+		case PairCodeIssued:
+			logger.Info("🔐 Pairing code: %s", update.Code)
+			logger.Info("⏳ Enter it in WhatsApp > Linked devices > Link with phone number within %v",
+				time.Until(update.Expires).Round(time.Second))
+		case PairLinked:
+			logger.Success("✅ Successfully paired!")
+			return nil
+		case PairFailed:
+			return update.Err
+		}
+	}
+	return fmt.Errorf("pairing ended unexpectedly")
+}
@@ -0,0 +1,62 @@
+package whatsapp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"yukii-bot/lib/logger"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+func (c *Client) SendPoll(to types.JID, question string, options []string, selectableCount int) (types.MessageID, error) {
+	if len(options) < 2 {
+		return "", fmt.Errorf("a poll needs at least 2 options")
+	}
+
+	id, err := c.send(to, c.client().BuildPollCreation(question, options, selectableCount))
+	if err != nil {
+		return "", err
+	}
+
+	recipient := c.getDisplayName(to)
+	logger.MessageOut(recipient, "poll", question, to.String())
+
+	return id, nil
+}
+
+// PollOptionHash returns the SHA-256 hash WhatsApp uses to refer to a poll
+// option inside an encrypted vote.
+func PollOptionHash(option string) []byte {
+	return whatsmeow.HashPollOptions([]string{option})[0]
+}
+
+// Selected maps the vote's option hashes back to option names. Hashes that
+// match none of options are ignored.
+func (v *PollVote) Selected(options []string) []string {
+	var selected []string
+	for _, option := range options {
+		hash := PollOptionHash(option)
+		for _, voted := range v.SelectedHashes {
+			if bytes.Equal(hash, voted) {
+				selected = append(selected, option)
+				break
+			}
+		}
+	}
+	return selected
+}
+
+func (c *Client) decryptPollVote(evt *events.Message, vote *PollVote) {
+	decrypted, err := c.client().DecryptPollVote(context.Background(), evt)
+	if err != nil {
+		logger.Warning("Failed to decrypt poll vote %s: %v", evt.Info.ID, err)
+		return
+	}
+
+	vote.SelectedHashes = decrypted.GetSelectedOptions()
+	vote.Decrypted = true
+}
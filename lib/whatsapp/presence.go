@@ -0,0 +1,74 @@
+package whatsapp
+
+import (
+	"time"
+
+	"yukii-bot/lib/logger"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+// WhatsApp clears a chat presence after about 25 seconds, so long running
+// indicators are refreshed before that.
+const presenceRefreshInterval = 10 * time.Second
+
+// SendTyping shows "typing..." in chat until SendPaused or a message is sent.
+func (c *Client) SendTyping(chat types.JID) error {
+	return c.client().SendChatPresence(chat, types.ChatPresenceComposing, types.ChatPresenceMediaText)
+}
+
+// SendRecording shows "recording audio..." in chat.
+func (c *Client) SendRecording(chat types.JID) error {
+	return c.client().SendChatPresence(chat, types.ChatPresenceComposing, types.ChatPresenceMediaAudio)
+}
+
+func (c *Client) SendPaused(chat types.JID) error {
+	return c.client().SendChatPresence(chat, types.ChatPresencePaused, "")
+}
+
+// StartTyping keeps the typing indicator (or the recording one when
+// recording is set) alive in chat until the returned function is called.
+func (c *Client) StartTyping(chat types.JID, recording bool) func() {
+	send := c.SendTyping
+	if recording {
+		send = c.SendRecording
+	}
+	if err := send(chat); err != nil {
+		logger.Debug("Failed to send chat presence: %v", err)
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(presenceRefreshInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				c.SendPaused(chat)
+				return
+			case <-ticker.C:
+				send(chat)
+			}
+		}
+	}()
+
+	stopped := false
+	return func() {
+		if !stopped {
+			stopped = true
+			close(done)
+		}
+	}
+}
+
+// SetOnline marks the bot as online or offline for its contacts. Being
+// online also makes WhatsApp deliver presence updates of others.
+func (c *Client) SetOnline(online bool) error {
+	presence := types.PresenceUnavailable
+	if online {
+		presence = types.PresenceAvailable
+	}
+	return c.client().SendPresence(presence)
+}
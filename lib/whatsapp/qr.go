@@ -0,0 +1,219 @@
+package whatsapp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"yukii-bot/lib/logger"
+
+	"github.com/skip2/go-qrcode"
+	"go.mau.fi/whatsmeow"
+)
+
+const (
+	qrPNGSize           = 512
+	qrCountdownInterval = 10 * time.Second
+)
+
+// RenderQR draws a QR code with half block characters, two modules per
+// character cell. Light modules are drawn so it scans on dark terminals.
+func RenderQR(code string) (string, error) {
+	qr, err := qrcode.New(code, qrcode.Low)
+	if err != nil {
+		return "", err
+	}
+
+	bitmap := qr.Bitmap()
+	var sb strings.Builder
+	for y := 0; y < len(bitmap); y += 2 {
+		for x := range bitmap[y] {
+			top := !bitmap[y][x]
+			bottom := y+1 < len(bitmap) && !bitmap[y+1][x]
+			switch {
+			case top && bottom:
+				sb.WriteString("█")
+			case top:
+				sb.WriteString("▀")
+			case bottom:
+				sb.WriteString("▄")
+			default:
+				sb.WriteString(" ")
+			}
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String(), nil
+}
+
+// qrServer serves the current QR code as a self refreshing page while a
+// login is waiting to be scanned.
+type qrServer struct {
+	server *http.Server
+	png    []byte
+	mu     sync.RWMutex
+}
+
+func startQRServer(addr string) (*qrServer, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &qrServer{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, `<!DOCTYPE html><html><head><meta http-equiv="refresh" content="5"><title>Yukii login</title></head>`+
+			`<body style="display:flex;justify-content:center;align-items:center;height:100vh;margin:0">`+
+			`<img src="/qr.png" alt="Waiting for QR code..."></body></html>`)
+	})
+	mux.HandleFunc("/qr.png", func(w http.ResponseWriter, r *http.Request) {
+		s.mu.RLock()
+		png := s.png
+		s.mu.RUnlock()
+
+		if png == nil {
+			http.Error(w, "no QR code yet", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		w.Header().Set("Cache-Control", "no-store")
+		w.Write(png)
+	})
+
+	s.server = &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		if err := s.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("QR server stopped: %v", err)
+		}
+	}()
+
+	logger.Info("🌐 QR code is also served at http://%s", listener.Addr())
+	return s, nil
+}
+
+func (s *qrServer) update(png []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.png = png
+}
+
+func (s *qrServer) close() {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	s.server.Shutdown(ctx)
+}
+
+func (c *Client) qrPNGPath() string {
+	return filepath.Join(c.config.SessionDir(c.session.Name), "qr.png")
+}
+
+// showQR prints the code to the terminal and publishes it as PNG where
+// configured.
+func (c *Client) showQR(code string, server *qrServer) {
+	rendered, err := RenderQR(code)
+	if err != nil {
+		logger.Error("Failed to render QR code: %v", err)
+		fmt.Println(code)
+	} else {
+		fmt.Print(rendered)
+	}
+
+	if !c.config.WhatsApp.QRPNG && server == nil {
+		return
+	}
+
+	png, err := qrcode.Encode(code, qrcode.Medium, qrPNGSize)
+	if err != nil {
+		logger.Error("Failed to encode QR code: %v", err)
+		return
+	}
+	if server != nil {
+		server.update(png)
+	}
+	if c.config.WhatsApp.QRPNG {
+		if err := os.WriteFile(c.qrPNGPath(), png, 0600); err != nil {
+			logger.Error("Failed to write QR code: %v", err)
+		} else {
+			logger.Info("🖼️ QR code saved to %s", c.qrPNGPath())
+		}
+	}
+}
+
+func (c *Client) loginQR() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	qrChan, err := c.client().GetQRChannel(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := c.client().Connect(); err != nil {
+		return err
+	}
+
+	var server *qrServer
+	if addr := c.config.WhatsApp.QRHTTPAddr; addr != "" {
+		if server, err = startQRServer(addr); err != nil {
+			logger.Error("Failed to start QR server: %v", err)
+		} else {
+			defer server.close()
+		}
+	}
+	if c.config.WhatsApp.QRPNG {
+		defer os.Remove(c.qrPNGPath())
+	}
+
+	timeout := time.Duration(c.config.WhatsApp.QRTimeout) * time.Second
+	if timeout <= 0 {
+		timeout = 3 * time.Minute
+	}
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(qrCountdownInterval)
+	defer ticker.Stop()
+
+	var expires time.Time
+	for {
+		select {
+		case evt, ok := <-qrChan:
+			if !ok {
+				return nil
+			}
+			switch evt.Event {
+			case whatsmeow.QRChannelEventCode:
+				logger.Info("📱 Please scan the QR code below:")
+				c.showQR(evt.Code, server)
+				expires = time.Now().Add(evt.Timeout)
+				logger.Info("⏳ QR code refreshes in %v", evt.Timeout.Round(time.Second))
+			case whatsmeow.QRChannelSuccess.Event:
+				logger.Success("✅ QR code scanned, logged in")
+				return nil
+			case whatsmeow.QRChannelTimeout.Event:
+				return fmt.Errorf("QR code was not scanned in time")
+			case whatsmeow.QRChannelEventError:
+				return fmt.Errorf("pairing failed: %v", evt.Error)
+			default:
+				return fmt.Errorf("QR login failed: %s", evt.Event)
+			}
+		case <-ticker.C:
+			if remaining := time.Until(expires); remaining > 0 {
+				logger.Info("⏳ QR code refreshes in %v", remaining.Round(time.Second))
+			}
+		case <-deadline.C:
+			c.client().Disconnect()
+			return fmt.Errorf("QR login timed out after %v", timeout)
+		}
+	}
+}
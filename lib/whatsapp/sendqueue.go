@@ -0,0 +1,384 @@
+package whatsapp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"yukii-bot/lib/logger"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"google.golang.org/protobuf/proto"
+)
+
+// Priority decides which queued message goes out first when the rate limit
+// is reached. Command replies are high, broadcasts low.
+type Priority int
+
+const (
+	PriorityLow Priority = iota
+	PriorityNormal
+	PriorityHigh
+)
+
+// ErrPassiveMode is returned for every send while auto_reply is off.
+var ErrPassiveMode = errors.New("auto reply is disabled")
+
+// ErrQueued is returned by the waiting sends when the client is offline and
+// the message was kept in the outbox, to be sent after reconnecting.
+var ErrQueued = errors.New("not connected, message kept in the offline queue")
+
+const (
+	retryBaseDelay = 500 * time.Millisecond
+	minTypingDelay = 500 * time.Millisecond
+	maxTypingDelay = 3 * time.Second
+	typingPerRune  = 30 * time.Millisecond
+)
+
+// send delivers msg through the send queue, or keeps it in the outbox while
+// the client is offline. The ID is picked up front so callers can still
+// edit or revoke queued messages.
+func (c *Client) send(to types.JID, msg *waE2E.Message) (types.MessageID, error) {
+	return c.sendWithPriority(to, msg, PriorityNormal)
+}
+
+// sendWithPriority queues a message and returns right away, so replies
+// from event handlers never wait for pacing or typing. Messages that hit
+// a disconnect move to the outbox, other failures are logged.
+func (c *Client) sendWithPriority(to types.JID, msg *waE2E.Message, priority Priority) (types.MessageID, error) {
+	return c.sendMessage(to, msg, priority, false)
+}
+
+// sendAndWait is sendWithPriority but waits until the message was sent or
+// failed for good.
+func (c *Client) sendAndWait(to types.JID, msg *waE2E.Message, priority Priority) (types.MessageID, error) {
+	return c.sendMessage(to, msg, priority, true)
+}
+
+func (c *Client) sendMessage(to types.JID, msg *waE2E.Message, priority Priority, wait bool) (types.MessageID, error) {
+	if c.IsPassive() {
+		return "", ErrPassiveMode
+	}
+
+	id := c.client().GenerateMessageID()
+	if c.State() != StateConnected {
+		return c.park(to, msg, id, wait)
+	}
+
+	err := c.queue.submit(to, msg, id, priority, wait)
+	if errors.Is(err, whatsmeow.ErrNotConnected) {
+		return c.park(to, msg, id, wait)
+	}
+	if err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// park keeps a message in the outbox. Callers that wait learn it wasn't
+// delivered yet through ErrQueued.
+func (c *Client) park(to types.JID, msg *waE2E.Message, id types.MessageID, wait bool) (types.MessageID, error) {
+	c.enqueue(to, msg, id)
+	if wait {
+		return id, ErrQueued
+	}
+	return id, nil
+}
+
+// IsPassive reports whether the bot only logs messages and never replies.
+func (c *Client) IsPassive() bool {
+	return !c.config.WhatsApp.AutoReply
+}
+
+// SendMessageWithPriority sends a text message with the given priority,
+// e.g. PriorityLow for bulk sends that should yield to command replies.
+func (c *Client) SendMessageWithPriority(to types.JID, text string, priority Priority) (types.MessageID, error) {
+	id, err := c.sendWithPriority(to, &waE2E.Message{Conversation: proto.String(text)}, priority)
+	if err != nil {
+		return "", err
+	}
+
+	recipient := c.getDisplayName(to)
+	logger.MessageOut(recipient, "text", text, to.String())
+
+	return id, nil
+}
+
+// SendMessageAndWait sends a text message and waits until it was delivered.
+// While offline it returns the ID with ErrQueued, the message is sent from
+// the outbox later.
+// It's meant for bulk senders that run on their own and count failures.
+func (c *Client) SendMessageAndWait(to types.JID, text string, priority Priority) (types.MessageID, error) {
+	id, err := c.sendAndWait(to, &waE2E.Message{Conversation: proto.String(text)}, priority)
+	if errors.Is(err, ErrQueued) {
+		return id, err
+	}
+	if err != nil {
+		return "", err
+	}
+
+	recipient := c.getDisplayName(to)
+	logger.MessageOut(recipient, "text", text, to.String())
+
+	return id, nil
+}
+
+type sendJob struct {
+	to       types.JID
+	msg      *waE2E.Message
+	id       types.MessageID
+	priority Priority
+	seq      uint64
+	// result receives the outcome when the sender waits for it.
+	result chan error
+}
+
+// sendQueue paces outgoing messages with a global token bucket and a
+// minimum interval per chat. Messages of a chat go out one at a time and in
+// the order they were sent; priorities decide between chats.
+type sendQueue struct {
+	client     *Client
+	pending    []*sendJob
+	busy       map[types.JID]bool
+	nextSend   map[types.JID]time.Time
+	tokens     float64
+	lastRefill time.Time
+	seq        uint64
+	lastPrune  time.Time
+	paused     bool
+	delivering sync.WaitGroup
+	wake       chan struct{}
+	mu         sync.Mutex
+}
+
+func newSendQueue(client *Client) *sendQueue {
+	q := &sendQueue{
+		client:     client,
+		busy:       make(map[types.JID]bool),
+		nextSend:   make(map[types.JID]time.Time),
+		tokens:     1,
+		lastRefill: time.Now(),
+		wake:       make(chan struct{}, 1),
+	}
+	go q.run()
+	return q
+}
+
+// submit queues a message. With wait it blocks until the message was sent
+// or failed for good, otherwise the queue deals with failures itself.
+func (q *sendQueue) submit(to types.JID, msg *waE2E.Message, id types.MessageID, priority Priority, wait bool) error {
+	job := &sendJob{
+		to:       to,
+		msg:      msg,
+		id:       id,
+		priority: priority,
+	}
+	if wait {
+		job.result = make(chan error, 1)
+	}
+
+	q.mu.Lock()
+	q.seq++
+	job.seq = q.seq
+	q.pending = append(q.pending, job)
+	q.mu.Unlock()
+
+	q.notify()
+	if !wait {
+		return nil
+	}
+	return <-job.result
+}
+
+// pause holds back pending messages and waits for the ones being delivered.
+func (q *sendQueue) pause() {
+	q.mu.Lock()
+	q.paused = true
+	q.mu.Unlock()
+
+	q.delivering.Wait()
+}
+
+func (q *sendQueue) resume() {
+	q.mu.Lock()
+	q.paused = false
+	q.mu.Unlock()
+
+	q.notify()
+}
+
+func (q *sendQueue) notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (q *sendQueue) run() {
+	for {
+		q.mu.Lock()
+		job, wait := q.next(time.Now())
+		q.mu.Unlock()
+
+		if job != nil {
+			go q.deliver(job)
+			continue
+		}
+
+		if wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-q.wake:
+			case <-timer.C:
+			}
+			timer.Stop()
+		} else {
+			<-q.wake
+		}
+	}
+}
+
+// next picks the most urgent job that may be sent now. Otherwise it returns
+// how long to wait for the next one, or zero to wait for a wake up.
+func (q *sendQueue) next(now time.Time) (*sendJob, time.Duration) {
+	q.prune(now)
+	if len(q.pending) == 0 || q.paused {
+		return nil, 0
+	}
+
+	cfg := q.client.config.Queue
+	if cfg.GlobalRate > 0 {
+		q.tokens += now.Sub(q.lastRefill).Seconds() * cfg.GlobalRate
+		q.tokens = min(q.tokens, max(1, cfg.GlobalRate))
+		q.lastRefill = now
+		if q.tokens < 1 {
+			return nil, time.Duration((1 - q.tokens) / cfg.GlobalRate * float64(time.Second))
+		}
+	}
+
+	// Pending jobs are in submit order and only the first of each chat may
+	// go, so priorities never reorder the messages of one chat.
+	best := -1
+	var wait time.Duration
+	first := make(map[types.JID]bool)
+	for i, job := range q.pending {
+		if first[job.to] {
+			continue
+		}
+		first[job.to] = true
+		if q.busy[job.to] {
+			continue
+		}
+		if ready := q.nextSend[job.to]; ready.After(now) {
+			if until := ready.Sub(now); wait == 0 || until < wait {
+				wait = until
+			}
+			continue
+		}
+		if best < 0 || job.priority > q.pending[best].priority ||
+			job.priority == q.pending[best].priority && job.seq < q.pending[best].seq {
+			best = i
+		}
+	}
+	if best < 0 {
+		return nil, wait
+	}
+
+	job := q.pending[best]
+	q.pending = append(q.pending[:best], q.pending[best+1:]...)
+	q.busy[job.to] = true
+	q.delivering.Add(1)
+	if cfg.GlobalRate > 0 {
+		q.tokens--
+	}
+	return job, 0
+}
+
+// prune forgets the pacing of chats that may send again anyway, at most
+// once a minute.
+func (q *sendQueue) prune(now time.Time) {
+	if now.Sub(q.lastPrune) < time.Minute {
+		return
+	}
+	q.lastPrune = now
+
+	for chat, ready := range q.nextSend {
+		if !ready.After(now) {
+			delete(q.nextSend, chat)
+		}
+	}
+}
+
+func (q *sendQueue) deliver(job *sendJob) {
+	defer q.delivering.Done()
+	c := q.client
+	cfg := c.config.Queue
+
+	if cfg.Typing {
+		c.simulateTyping(job.to, job.msg)
+	}
+
+	var err error
+	for attempt := 0; ; attempt++ {
+		_, err = c.client().SendMessage(context.Background(), job.to, job.msg, whatsmeow.SendRequestExtra{ID: job.id})
+		if err == nil || !isTransientError(err) || attempt >= cfg.MaxRetries {
+			break
+		}
+
+		delay := retryBaseDelay << attempt
+		logger.Warning("⚠️ Sending to %s failed (%v), retrying in %v", job.to.User, err, delay)
+		time.Sleep(delay)
+	}
+
+	q.mu.Lock()
+	delete(q.busy, job.to)
+	q.nextSend[job.to] = time.Now().Add(time.Duration(cfg.ChatInterval) * time.Millisecond)
+	q.mu.Unlock()
+	q.notify()
+
+	switch {
+	case job.result != nil:
+		job.result <- err
+	case errors.Is(err, whatsmeow.ErrNotConnected):
+		c.enqueue(job.to, job.msg, job.id)
+	case err != nil:
+		logger.Error("Failed to send message to %s: %v", job.to.User, err)
+	}
+}
+
+// simulateTyping shows "typing..." for a time that grows with the length
+// of text messages. Other messages are sent right away. It runs in the
+// delivery of the message, the chat waits but the sender doesn't.
+func (c *Client) simulateTyping(to types.JID, msg *waE2E.Message) {
+	text := msg.GetConversation()
+	if text == "" {
+		text = msg.GetExtendedTextMessage().GetText()
+	}
+	if text == "" {
+		return
+	}
+
+	delay := time.Duration(len([]rune(text))) * typingPerRune
+	delay = min(max(delay, minTypingDelay), maxTypingDelay)
+
+	if err := c.SendTyping(to); err != nil {
+		return
+	}
+	time.Sleep(delay)
+	c.SendPaused(to)
+}
+
+func isTransientError(err error) bool {
+	if errors.Is(err, whatsmeow.ErrIQTimedOut) ||
+		errors.Is(err, whatsmeow.ErrMessageTimedOut) ||
+		errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
@@ -0,0 +1,122 @@
+package whatsapp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"yukii-bot/lib/logger"
+)
+
+var ErrStoreInUse = errors.New("session store is in use by another process, stop the bot first")
+
+var ErrNotSQLite = errors.New("only SQLite session stores are files, use the database's own tools for postgres")
+
+const logoutConnectTimeout = 15 * time.Second
+
+// IsLoggedIn reports whether the session store holds a linked device.
+func (c *Client) IsLoggedIn() bool {
+	return c.client().Store.ID != nil
+}
+
+// Logout unlinks this device from the phone and wipes the session store.
+// When WhatsApp can't be reached only the local store is wiped, and the
+// device stays listed on the phone until it is removed there.
+func (c *Client) Logout(ctx context.Context) error {
+	c.stopSupervising()
+	if !c.IsLoggedIn() {
+		return fmt.Errorf("not logged in")
+	}
+
+	if c.waitConnected(ctx) {
+		c.SetOnline(false)
+		err := c.client().Logout(ctx)
+		if err == nil {
+			c.setState(StateLoggedOut)
+			logger.Info("📴 Logged out of WhatsApp")
+			return nil
+		}
+		logger.Warning("Failed to log out on WhatsApp, wiping the local session only: %v", err)
+	} else {
+		logger.Warning("WhatsApp is unreachable, wiping the local session only")
+	}
+
+	c.client().Disconnect()
+	if err := c.client().Store.Delete(ctx); err != nil {
+		return fmt.Errorf("failed to wipe session: %w", err)
+	}
+	c.setState(StateLoggedOut)
+	return nil
+}
+
+// Repair logs out if needed and links the account again with the
+// configured auth mode. Supervision is stopped; call Supervise again once
+// Repair returns.
+func (c *Client) Repair(ctx context.Context) error {
+	if c.IsLoggedIn() {
+		if err := c.Logout(ctx); err != nil {
+			return err
+		}
+	} else {
+		c.stopSupervising()
+	}
+
+	c.loginMutex.Lock()
+	defer c.loginMutex.Unlock()
+
+	c.resetDevice()
+	c.setState(StateConnecting)
+	c.isConnecting = true
+	err := c.login()
+	c.isConnecting = false
+	if err != nil {
+		c.setState(StateLoggedOut)
+		return err
+	}
+	return nil
+}
+
+// Close disconnects and releases the session store, so another client can
+// open it.
+func (c *Client) Close() error {
+	c.Disconnect()
+	err := c.container.Close()
+	c.lock.unlock()
+	return err
+}
+
+// waitConnected connects if needed and waits until WhatsApp accepted the
+// session.
+func (c *Client) waitConnected(ctx context.Context) bool {
+	if c.State() == StateConnected {
+		return true
+	}
+
+	states, unsubscribe := c.Subscribe()
+	defer unsubscribe()
+
+	if !c.client().IsConnected() {
+		c.setState(StateConnecting)
+		if err := c.client().Connect(); err != nil {
+			c.setState(StateDisconnected)
+			return false
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, logoutConnectTimeout)
+	defer cancel()
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case state := <-states:
+			switch state {
+			case StateConnected:
+				return true
+			case StateDisconnected, StateLoggedOut, StateReplaced:
+				return false
+			}
+		}
+	}
+}
@@ -1,19 +1,24 @@
 package main
 
 import (
+	"bufio"
 	"context"
 	"flag"
+	"fmt"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"yukii-bot/lib/config"
 	"yukii-bot/lib/database"
 	"yukii-bot/lib/logger"
+	"yukii-bot/lib/session"
 	"yukii-bot/lib/whatsapp"
-	"yukii-bot/plugins"
+	_ "github.com/lib/pq"
 	_ "github.com/mattn/go-sqlite3"
+	"golang.org/x/term"
 )
 
 var (
@@ -32,40 +37,60 @@ func main() {
 		logger.Fatal("Failed to load configuration", err)
 	}
 	
-	db, err := database.Init(cfg.Database.Path)
+	if flag.NArg() > 0 {
+		if err := runCommand(cfg, flag.Args()); err != nil {
+			logger.Fatal("Command failed", err)
+		}
+		return
+	}
+	
+	db, err := database.Open(database.Options{
+		Driver:         cfg.Database.Driver,
+		Path:           cfg.Database.Path,
+		Backups:        cfg.Database.Backups,
+		BackupInterval: time.Duration(cfg.Database.BackupInterval) * time.Second,
+		FlushInterval:  time.Duration(cfg.Database.FlushInterval) * time.Millisecond,
+		FlushThreshold: cfg.Database.FlushThreshold,
+	})
 	if err != nil {
 		logger.Fatal("Failed to initialize database", err)
 	}
 	defer db.Close()
 	
-	client, err := whatsapp.NewClient(cfg, db)
-	if err != nil {
-		logger.Fatal("Failed to create WhatsApp client", err)
-	}
-
-	if *qrMode {
-		client.SetAuthMode(whatsapp.AuthModeQR)
-	} else if *pairCode != "" {
-		client.SetAuthMode(whatsapp.AuthModePair)
-		client.SetPairCode(*pairCode)
-	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 	
-	pluginManager := plugins.NewManager(client, db)
+	pool := session.NewPool(ctx, cfg, db)
 	
-	if err := pluginManager.LoadPlugins(); err != nil {
-		logger.Fatal("Failed to load plugins", err)
+	var sessions []*session.Session
+	for i, sc := range cfg.GetSessions() {
+		s, err := pool.Create(sc)
+		if err != nil {
+			logger.Fatal("Failed to create session "+sc.Name, err)
+		}
+		
+		// The auth flags apply to the first account only.
+		if i == 0 {
+			if *qrMode {
+				s.Client.SetAuthMode(whatsapp.AuthModeQR)
+			} else if *pairCode != "" {
+				s.Client.SetAuthMode(whatsapp.AuthModePair)
+				s.Client.SetPairCode(*pairCode)
+			}
+		}
+		sessions = append(sessions, s)
 	}
 	
-	client.SetMessageHandler(pluginManager.HandleMessage)
-	
-	if err := client.Connect(); err != nil {
-		logger.Fatal("Failed to connect to WhatsApp", err)
+	for _, s := range sessions {
+		if err := pool.Start(s); err != nil {
+			logger.Error("Failed to connect to WhatsApp: %v", err)
+		}
 	}
 	
-	logger.Info("🚀 Yukii Bot is starting...")
-	
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	logger.Info("🚀 Yukii Bot is starting with %d session(s)...", len(sessions))
+	if !cfg.WhatsApp.AutoReply {
+		logger.Info("👀 auto_reply is off, running in passive mode")
+	}
 	
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
@@ -75,7 +100,7 @@ func main() {
 		logger.Info("📴 Shutting down gracefully...")
 		cancel()
 		
-		client.Disconnect()
+		pool.StopAll()
 
 		time.Sleep(2 * time.Second)
 		
@@ -84,4 +109,193 @@ func main() {
 	case <-ctx.Done():
 		logger.Info("📴 Context cancelled, shutting down...")
 	}
-}
\ No newline at end of file
+}
+
+const cliUsage = `Usage: yukii-bot [flags] [command]
+
+Commands work on a stopped bot, the session store can't be shared:
+  logout  [-session name]                      unlink the account and wipe its session
+  repair  [-session name] [-pair phone]        wipe the session and link the account again
+  backup  [-session name] -out file            write an encrypted backup of the session
+  restore [-session name] -in file [-force]    restore a session from a backup
+  migrate-store [-session name]                copy a SQLite session to the postgres store
+
+The backup passphrase is read from YUKII_BACKUP_PASSPHRASE or asked for.`
+
+// runCommand runs a session maintenance command instead of the bot.
+func runCommand(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet(args[0], flag.ExitOnError)
+	name := fs.String("session", config.DefaultSession, "Session to work on")
+
+	switch args[0] {
+	case "logout":
+		fs.Parse(args[1:])
+		client, err := openClient(cfg, *name)
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+
+		if !client.IsLoggedIn() {
+			logger.Info("Session %s is not logged in", *name)
+			return nil
+		}
+		return client.Logout(context.Background())
+
+	case "repair":
+		phone := fs.String("pair", "", "Phone number for pairing code authentication")
+		fs.Parse(args[1:])
+		client, err := openClient(cfg, *name)
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+
+		if *phone != "" {
+			client.SetAuthMode(whatsapp.AuthModePair)
+			client.SetPairCode(*phone)
+		}
+		if err := client.Repair(context.Background()); err != nil {
+			return err
+		}
+		logger.Success("✅ Session %s is linked again", *name)
+		return nil
+
+	case "backup":
+		out := fs.String("out", "", "File to write the backup to")
+		fs.Parse(args[1:])
+		if *out == "" {
+			return fmt.Errorf("-out is required")
+		}
+		client, err := openClient(cfg, *name)
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+
+		passphrase, err := readPassphrase(true)
+		if err != nil {
+			return err
+		}
+		file, err := os.OpenFile(*out, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0600)
+		if err != nil {
+			return err
+		}
+		if err := client.Backup(file, passphrase); err != nil {
+			file.Close()
+			os.Remove(*out)
+			return err
+		}
+		if err := file.Close(); err != nil {
+			return err
+		}
+		logger.Success("✅ Session %s backed up to %s", *name, *out)
+		return nil
+
+	case "restore":
+		in := fs.String("in", "", "Backup file to restore")
+		force := fs.Bool("force", false, "Replace an existing session")
+		fs.Parse(args[1:])
+		if *in == "" {
+			return fmt.Errorf("-in is required")
+		}
+		if _, err := findSession(cfg, *name); err != nil {
+			return err
+		}
+
+		file, err := os.Open(*in)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		passphrase, err := readPassphrase(false)
+		if err != nil {
+			return err
+		}
+		if err := whatsapp.RestoreSession(cfg, *name, file, passphrase, *force); err != nil {
+			return err
+		}
+		logger.Success("✅ Session %s restored from %s", *name, *in)
+		return nil
+
+	case "migrate-store":
+		fs.Parse(args[1:])
+		sc, err := findSession(cfg, *name)
+		if err != nil {
+			return err
+		}
+		if err := whatsapp.MigrateStore(context.Background(), cfg, sc); err != nil {
+			return err
+		}
+		logger.Success("✅ Session %s migrated, %s is no longer used", *name, cfg.SessionStoreFile(*name))
+		return nil
+
+	case "help":
+		fmt.Println(cliUsage)
+		return nil
+	}
+
+	fmt.Println(cliUsage)
+	return fmt.Errorf("unknown command %q", args[0])
+}
+
+func findSession(cfg *config.Config, name string) (config.Session, error) {
+	for _, sc := range cfg.GetSessions() {
+		if sc.Name == name {
+			return sc, nil
+		}
+	}
+	return config.Session{}, fmt.Errorf("no session named %s in %s", name, config.Path)
+}
+
+// openClient opens the session store of an account without connecting.
+// Opening fails while the bot is running on it.
+func openClient(cfg *config.Config, name string) (*whatsapp.Client, error) {
+	sc, err := findSession(cfg, name)
+	if err != nil {
+		return nil, err
+	}
+	// The commands never handle messages, so no database is needed.
+	return whatsapp.NewClient(cfg, sc, nil)
+}
+
+func readPassphrase(confirm bool) (string, error) {
+	if passphrase := os.Getenv("YUKII_BACKUP_PASSPHRASE"); passphrase != "" {
+		return passphrase, nil
+	}
+
+	// The passphrase isn't echoed on a terminal. Piped input is read as is.
+	reader := bufio.NewReader(os.Stdin)
+	ask := func(prompt string) (string, error) {
+		fmt.Print(prompt)
+		if fd := int(os.Stdin.Fd()); term.IsTerminal(fd) {
+			passphrase, err := term.ReadPassword(fd)
+			fmt.Println()
+			return string(passphrase), err
+		}
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+
+	passphrase, err := ask("Backup passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if passphrase == "" {
+		return "", fmt.Errorf("passphrase is required")
+	}
+	if confirm {
+		again, err := ask("Repeat passphrase: ")
+		if err != nil {
+			return "", err
+		}
+		if again != passphrase {
+			return "", fmt.Errorf("passphrases don't match")
+		}
+	}
+	return passphrase, nil
+}
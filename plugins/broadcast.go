@@ -0,0 +1,234 @@
+package plugins
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"yukii-bot/lib/database"
+	"yukii-bot/lib/logger"
+	"yukii-bot/lib/whatsapp"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+const broadcastProgressInterval = 5 * time.Second
+
+// knownUsers lists the users stored in the database, once each even when
+// older records were kept per device.
+func knownUsers(db *database.Database) []types.JID {
+	var users []types.JID
+	seen := make(map[types.JID]bool)
+	for key := range db.GetMap("users") {
+		jid, err := types.ParseJID(key)
+		if err != nil || jid.User == "" {
+			continue
+		}
+		jid = jid.ToNonAD()
+		if seen[jid] {
+			continue
+		}
+		seen[jid] = true
+		users = append(users, jid)
+	}
+	return users
+}
+
+type broadcastRun struct {
+	target  string
+	total   int
+	sent    int
+	// pending were kept in the offline queue and go out after reconnecting.
+	pending int
+	failed  []string
+	started time.Time
+	cancel  context.CancelFunc
+}
+
+type BroadcastPlugin struct {
+	BasePlugin
+	current *broadcastRun
+	mu      sync.Mutex
+}
+
+func NewBroadcastPlugin() *BroadcastPlugin {
+	return &BroadcastPlugin{
+		BasePlugin: BasePlugin{
+			PluginName:        "Broadcast",
+			PluginDescription: "Send an announcement to all groups and/or known users",
+			PluginUsage:       "broadcast [groups|users|all] <text> | broadcast status|cancel",
+			PluginCategory:    "Owner",
+			PluginAliases:     []string{"bc"},
+			NoPrefix:          false,
+			PluginType:        PluginTypeCommand,
+		},
+	}
+}
+
+func (p *BroadcastPlugin) Execute(ctx *Context) error {
+	if !ctx.IsOwner() {
+		return ctx.Reply("❌ This command is for the bot owner only")
+	}
+
+	target := strings.ToLower(ctx.GetArg(0))
+	switch target {
+	case "status":
+		return ctx.Reply(p.status())
+	case "cancel", "stop":
+		return p.stop(ctx)
+	case "groups", "users", "all":
+		return p.start(ctx, target, ctx.GetRawArgs(1))
+	}
+	return p.start(ctx, "groups", ctx.GetRawArgs(0))
+}
+
+func (p *BroadcastPlugin) status() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	run := p.current
+	if run == nil {
+		return "📢 No broadcast is running"
+	}
+	return fmt.Sprintf("📢 Broadcasting to %s: %d/%d sent, %d pending, %d failed (%s)",
+		run.target, run.sent, run.total, run.pending, len(run.failed), time.Since(run.started).Round(time.Second))
+}
+
+func (p *BroadcastPlugin) stop(ctx *Context) error {
+	p.mu.Lock()
+	run := p.current
+	p.mu.Unlock()
+
+	if run == nil {
+		return ctx.Reply("📢 No broadcast is running")
+	}
+	run.cancel()
+	return ctx.React("🛑")
+}
+
+func (p *BroadcastPlugin) targets(ctx *Context, target string) ([]types.JID, error) {
+	var chats []types.JID
+	if target == "groups" || target == "all" {
+		groups, err := ctx.Client.GetJoinedGroups()
+		if err != nil {
+			return nil, err
+		}
+		for _, group := range groups {
+			// Announcement groups only accept messages from admins.
+			if group.IsAnnounce {
+				if isAdmin, err := ctx.Client.IsBotGroupAdmin(group.JID); err != nil || !isAdmin {
+					continue
+				}
+			}
+			chats = append(chats, group.JID)
+		}
+	}
+	if target == "users" || target == "all" {
+		chats = append(chats, knownUsers(ctx.Database)...)
+	}
+	return chats, nil
+}
+
+func (p *BroadcastPlugin) start(ctx *Context, target, text string) error {
+	if text == "" {
+		return ctx.Reply(fmt.Sprintf("📢 Usage: *%s%s*", ctx.Prefix, p.Usage()))
+	}
+
+	chats, err := p.targets(ctx, target)
+	if err != nil {
+		return err
+	}
+	if len(chats) == 0 {
+		return ctx.Reply("📢 No chats to broadcast to")
+	}
+
+	runCtx, cancel := context.WithCancel(context.Background())
+	run := &broadcastRun{
+		target:  target,
+		total:   len(chats),
+		started: time.Now(),
+		cancel:  cancel,
+	}
+
+	p.mu.Lock()
+	if p.current != nil {
+		p.mu.Unlock()
+		cancel()
+		return ctx.Reply("❌ A broadcast is already running, check *" + ctx.Prefix + "broadcast status*")
+	}
+	p.current = run
+	p.mu.Unlock()
+
+	progressID, err := ctx.SendProgress(fmt.Sprintf("📢 Broadcasting to %d chats...", len(chats)))
+	if err != nil {
+		logger.Error("Failed to send broadcast progress: %v", err)
+	}
+
+	go p.run(runCtx, ctx, run, chats, "📢 *Broadcast*\n\n"+text, progressID)
+	return nil
+}
+
+func (p *BroadcastPlugin) run(runCtx context.Context, ctx *Context, run *broadcastRun, chats []types.JID, text, progressID string) {
+	defer func() {
+		run.cancel()
+		p.mu.Lock()
+		p.current = nil
+		p.mu.Unlock()
+	}()
+
+	logger.Info("📢 Broadcasting to %d chats", len(chats))
+	lastProgress := time.Now()
+
+	for _, chat := range chats {
+		if runCtx.Err() != nil {
+			break
+		}
+
+		_, err := ctx.Client.SendMessageAndWait(chat, text, whatsapp.PriorityLow)
+
+		p.mu.Lock()
+		if errors.Is(err, whatsapp.ErrQueued) {
+			run.pending++
+		} else if err != nil {
+			logger.Error("Broadcast to %s failed: %v", chat.User, err)
+			run.failed = append(run.failed, chat.User)
+		} else {
+			run.sent++
+		}
+		p.mu.Unlock()
+
+		if progressID != "" && time.Since(lastProgress) >= broadcastProgressInterval {
+			lastProgress = time.Now()
+			if err := ctx.Edit(progressID, p.status()); err != nil {
+				logger.Error("Failed to update broadcast progress: %v", err)
+			}
+		}
+	}
+
+	p.mu.Lock()
+	report := fmt.Sprintf("📢 Broadcast to %s finished in %s\n\n✅ Sent: %d/%d\n⏳ Pending: %d (offline, sent after reconnecting)\n❌ Failed: %d",
+		run.target, time.Since(run.started).Round(time.Second), run.sent, run.total, run.pending, len(run.failed))
+	if runCtx.Err() != nil {
+		report = strings.Replace(report, "finished", "cancelled", 1)
+	}
+	if len(run.failed) > 0 {
+		failed := run.failed
+		if len(failed) > 10 {
+			failed = append(failed[:10:10], fmt.Sprintf("and %d more", len(run.failed)-10))
+		}
+		report += "\n" + strings.Join(failed, "\n")
+	}
+	p.mu.Unlock()
+
+	if progressID != "" {
+		if err := ctx.Edit(progressID, report); err == nil {
+			return
+		}
+	}
+	if err := ctx.Send(report); err != nil {
+		logger.Error("Failed to send broadcast report: %v", err)
+	}
+}
@@ -0,0 +1,213 @@
+package plugins
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"yukii-bot/lib/whatsapp"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+// checkGroupAdmin replies with the reason and returns false when the command
+// cannot run: outside groups, for non-admins, or when the bot is no admin.
+func checkGroupAdmin(ctx *Context) (bool, error) {
+	if !ctx.IsGroup() {
+		return false, ctx.Reply("❌ This command can only be used in groups")
+	}
+	if !ctx.IsGroupAdmin() && !ctx.IsOwner() {
+		return false, ctx.Reply("❌ This command is for group admins only")
+	}
+	if !ctx.IsBotAdmin() {
+		return false, ctx.Reply("❌ I need to be a group admin to do that")
+	}
+	return true, nil
+}
+
+func targetUsers(ctx *Context) []types.JID {
+	targets := ctx.GetMentionedArgs()
+	if len(targets) == 0 && !ctx.Message.QuotedSender.IsEmpty() {
+		targets = append(targets, ctx.Message.QuotedSender)
+	}
+	return targets
+}
+
+type GroupMemberPlugin struct {
+	BasePlugin
+	action string
+}
+
+func newGroupMemberPlugin(name, action, description string, aliases []string) *GroupMemberPlugin {
+	return &GroupMemberPlugin{
+		BasePlugin: BasePlugin{
+			PluginName:        name,
+			PluginDescription: description,
+			PluginUsage:       strings.ToLower(name) + " @user (or reply to a message)",
+			PluginCategory:    "Group",
+			PluginAliases:     aliases,
+			NoPrefix:          false,
+			PluginType:        PluginTypeCommand,
+		},
+		action: action,
+	}
+}
+
+func NewKickPlugin() *GroupMemberPlugin {
+	return newGroupMemberPlugin("Kick", "remove", "Remove members from the group", []string{"remove"})
+}
+
+func NewAddPlugin() *GroupMemberPlugin {
+	return newGroupMemberPlugin("Add", "add", "Add members to the group", []string{"invite"})
+}
+
+func NewPromotePlugin() *GroupMemberPlugin {
+	return newGroupMemberPlugin("Promote", "promote", "Make members group admins", []string{})
+}
+
+func NewDemotePlugin() *GroupMemberPlugin {
+	return newGroupMemberPlugin("Demote", "demote", "Remove admin rights from members", []string{})
+}
+
+func (p *GroupMemberPlugin) Execute(ctx *Context) error {
+	if ok, err := checkGroupAdmin(ctx); !ok {
+		return err
+	}
+
+	targets := targetUsers(ctx)
+	if len(targets) == 0 {
+		return ctx.Reply(fmt.Sprintf("👥 Usage: *%s%s*", ctx.Prefix, p.Usage()))
+	}
+
+	group := ctx.Message.From
+	var err error
+	switch p.action {
+	case "add":
+		_, err = ctx.Client.AddParticipants(group, targets...)
+	case "remove":
+		_, err = ctx.Client.KickParticipants(group, targets...)
+	case "promote":
+		_, err = ctx.Client.PromoteParticipants(group, targets...)
+	case "demote":
+		_, err = ctx.Client.DemoteParticipants(group, targets...)
+	}
+	if err != nil {
+		return err
+	}
+
+	return ctx.React("✅")
+}
+
+type GroupPlugin struct {
+	BasePlugin
+}
+
+func NewGroupPlugin() *GroupPlugin {
+	return &GroupPlugin{
+		BasePlugin: BasePlugin{
+			PluginName:        "Group",
+			PluginDescription: "Show group info or change group settings",
+			PluginUsage:       "group [info|open|close|lock|unlock|name <text>|desc <text>|link|revoke]",
+			PluginCategory:    "Group",
+			PluginAliases:     []string{"gc"},
+			NoPrefix:          false,
+			PluginType:        PluginTypeCommand,
+		},
+	}
+}
+
+func (p *GroupPlugin) Execute(ctx *Context) error {
+	if !ctx.IsGroup() {
+		return ctx.Reply("❌ This command can only be used in groups")
+	}
+
+	action := strings.ToLower(ctx.GetArg(0))
+	if action == "" || action == "info" {
+		return p.showInfo(ctx)
+	}
+
+	if ok, err := checkGroupAdmin(ctx); !ok {
+		return err
+	}
+
+	group := ctx.Message.From
+	text := strings.TrimSpace(strings.Join(ctx.Args[1:], " "))
+
+	switch action {
+	case "open":
+		return p.done(ctx, ctx.Client.SetGroupAnnounce(group, false))
+	case "close":
+		return p.done(ctx, ctx.Client.SetGroupAnnounce(group, true))
+	case "lock":
+		return p.done(ctx, ctx.Client.SetGroupLocked(group, true))
+	case "unlock":
+		return p.done(ctx, ctx.Client.SetGroupLocked(group, false))
+	case "name", "subject":
+		if text == "" {
+			return ctx.Reply("❌ Please provide the new group name")
+		}
+		return p.done(ctx, ctx.Client.SetGroupSubject(group, text))
+	case "desc", "description":
+		return p.done(ctx, ctx.Client.SetGroupDescription(group, text))
+	case "link":
+		link, err := ctx.Client.GetGroupInviteLink(group)
+		if err != nil {
+			return err
+		}
+		return ctx.Reply("🔗 " + link)
+	case "revoke", "reset":
+		link, err := ctx.Client.ResetGroupInviteLink(group)
+		if err != nil {
+			return err
+		}
+		return ctx.Reply("🔗 New invite link: " + link)
+	}
+
+	return ctx.Reply(fmt.Sprintf("👥 Usage: *%s%s*", ctx.Prefix, p.Usage()))
+}
+
+func (p *GroupPlugin) done(ctx *Context, err error) error {
+	if err != nil {
+		return err
+	}
+	return ctx.React("✅")
+}
+
+func (p *GroupPlugin) showInfo(ctx *Context) error {
+	info, err := ctx.GetGroupInfo()
+	if err != nil {
+		return err
+	}
+
+	admins := whatsapp.GroupAdmins(info)
+	adminTags := make([]string, 0, len(admins))
+	for _, admin := range admins {
+		adminTags = append(adminTags, whatsapp.MentionText(admin))
+	}
+
+	onOff := func(enabled bool) string {
+		if enabled {
+			return "On"
+		}
+		return "Off"
+	}
+
+	response := fmt.Sprintf(`👥 *%s*
+
+📝 *Description:* %s
+📅 *Created:* %s
+👤 *Members:* %d
+🛡️ *Admins:* %s
+
+📢 *Admins only chat:* %s
+🔒 *Admins only edit:* %s`,
+		info.Name,
+		info.Topic,
+		info.GroupCreated.Format(time.DateOnly),
+		len(info.Participants),
+		strings.Join(adminTags, " "),
+		onOff(info.IsAnnounce),
+		onOff(info.IsLocked))
+
+	return ctx.ReplyWithMentions(response, admins)
+}
@@ -3,10 +3,16 @@ package plugins
 import (
 	"fmt"
 	"strings"
+	"time"
+	"unicode"
 
+	"yukii-bot/lib/config"
 	"yukii-bot/lib/database"
 	"yukii-bot/lib/logger"
+	"yukii-bot/lib/scheduler"
 	"yukii-bot/lib/whatsapp"
+
+	"go.mau.fi/whatsmeow/types"
 )
 
 type PluginType int
@@ -16,6 +22,8 @@ const (
 	PluginTypeCommand
 	PluginTypeAll
 	PluginTypeAfter
+	PluginTypeEvent
+	PluginTypeGroupEvent
 )
 
 type Plugin interface {
@@ -24,6 +32,8 @@ type Plugin interface {
 	Usage() string
 	Category() string
 	Aliases() []string
+	Type() PluginType
+	ShowsTyping() bool
 	Execute(ctx *Context) error
 }
 
@@ -35,6 +45,9 @@ type BasePlugin struct {
 	PluginAliases     []string
 	NoPrefix          bool
 	PluginType        PluginType
+	// AutoTyping shows "typing..." in the chat while the command runs. With
+	// queue.typing on, the send queue shows it before each reply instead.
+	AutoTyping        bool
 }
 
 func (p *BasePlugin) Name() string        { return p.PluginName }
@@ -42,19 +55,66 @@ func (p *BasePlugin) Description() string { return p.PluginDescription }
 func (p *BasePlugin) Usage() string       { return p.PluginUsage }
 func (p *BasePlugin) Category() string    { return p.PluginCategory }
 func (p *BasePlugin) Aliases() []string   { return p.PluginAliases }
+func (p *BasePlugin) Type() PluginType    { return p.PluginType }
+func (p *BasePlugin) ShowsTyping() bool   { return p.AutoTyping }
+
+// SessionInfo describes one account run by the process.
+type SessionInfo struct {
+	Name   string
+	JID    string
+	Prefix string
+	State  whatsapp.ConnectionState
+}
+
+// SessionController starts and stops accounts at runtime. It lives outside
+// this package since every session owns its own Manager.
+type SessionController interface {
+	ListSessions() []SessionInfo
+	// AddSession starts an account in the background. onPair, when not nil,
+	// receives the pairing progress of accounts logging in by phone number.
+	AddSession(session config.Session, onPair whatsapp.PairHandler) error
+	RemoveSession(name string) error
+}
 
 type Context struct {
 	Client    *whatsapp.Client
 	Database  *database.Database
+	Scheduler *scheduler.Scheduler
+	Sessions  SessionController
+	Account   string
 	Message   *whatsapp.Message
+	User      *database.User
 	Command   string
 	Args      []string
 	Body      string
 	Prefix    string
 	IsCommand bool
+
+	GroupEvent *whatsapp.GroupEvent
+
+	stopped bool
+}
+
+// Stop prevents the remaining stages from seeing the message. Before-stage
+// plugins use it to drop messages, e.g. from users that were just kicked.
+func (ctx *Context) Stop() {
+	ctx.stopped = true
+}
+
+func (ctx *Context) IsStopped() bool {
+	return ctx.stopped
+}
+
+// isSynthetic reports whether the message was built by the bot itself, e.g.
+// for a scheduled command, so there is nothing to quote or react to.
+func (ctx *Context) isSynthetic() bool {
+	return ctx.Message.ID == ""
 }
 
 func (ctx *Context) Reply(text string) error {
+	if ctx.isSynthetic() {
+		return ctx.Send(text)
+	}
 	return ctx.Client.SendReply(ctx.Message, text)
 }
 
@@ -62,6 +122,54 @@ func (ctx *Context) Send(text string) error {
 	return ctx.Client.SendMessage(ctx.Message.From, text)
 }
 
+func (ctx *Context) ReplyWithMentions(text string, mentions []types.JID) error {
+	if ctx.isSynthetic() {
+		return ctx.SendWithMentions(text, mentions)
+	}
+	return ctx.Client.SendReplyWithMentions(ctx.Message, text, mentions)
+}
+
+func (ctx *Context) SendWithMentions(text string, mentions []types.JID) error {
+	return ctx.Client.SendMessageWithMentions(ctx.Message.From, text, mentions)
+}
+
+func (ctx *Context) GetMentions() []types.JID {
+	return ctx.Message.Mentions
+}
+
+func (ctx *Context) GetMentionedArgs() []types.JID {
+	return whatsapp.ResolveMentions(ctx.Message, ctx.Args)
+}
+
+func (ctx *Context) GetMentionArg(index int) (types.JID, bool) {
+	return whatsapp.ResolveMention(ctx.Message, ctx.GetArg(index))
+}
+
+func (ctx *Context) React(emoji string) error {
+	if ctx.isSynthetic() {
+		return nil
+	}
+	return ctx.Client.React(ctx.Message, emoji)
+}
+
+// Typing shows "typing..." (or "recording audio..." when recording is set)
+// until the returned function is called or a message is sent.
+func (ctx *Context) Typing(recording bool) func() {
+	return ctx.Client.StartTyping(ctx.Message.From, recording)
+}
+
+func (ctx *Context) SendProgress(text string) (string, error) {
+	return ctx.Client.SendTextMessage(ctx.Message.From, text)
+}
+
+func (ctx *Context) Edit(msgID, text string) error {
+	return ctx.Client.EditMessage(ctx.Message.From, msgID, text)
+}
+
+func (ctx *Context) Revoke() error {
+	return ctx.Client.Revoke(ctx.Message)
+}
+
 func (ctx *Context) GetArg(index int) string {
 	if index >= 0 && index < len(ctx.Args) {
 		return ctx.Args[index]
@@ -69,6 +177,21 @@ func (ctx *Context) GetArg(index int) string {
 	return ""
 }
 
+// GetRawArgs returns the body after the command and the first skip
+// arguments, keeping the original spacing and line breaks.
+func (ctx *Context) GetRawArgs(skip int) string {
+	body := ctx.Body
+	for i := 0; i <= skip; i++ {
+		body = strings.TrimLeftFunc(body, unicode.IsSpace)
+		end := strings.IndexFunc(body, unicode.IsSpace)
+		if end < 0 {
+			return ""
+		}
+		body = body[end:]
+	}
+	return strings.TrimSpace(body)
+}
+
 func (ctx *Context) GetArgs() []string {
 	return ctx.Args
 }
@@ -81,6 +204,42 @@ func (ctx *Context) IsGroup() bool {
 	return ctx.Message.IsGroup
 }
 
+func (ctx *Context) GetGroupInfo() (*types.GroupInfo, error) {
+	if !ctx.Message.IsGroup {
+		return nil, fmt.Errorf("not a group chat")
+	}
+	return ctx.Client.GetGroupInfo(ctx.Message.From)
+}
+
+func (ctx *Context) IsGroupAdmin() bool {
+	info, err := ctx.GetGroupInfo()
+	if err != nil {
+		return false
+	}
+	return whatsapp.IsGroupAdmin(info, ctx.Message.SenderJIDs()...)
+}
+
+func (ctx *Context) IsBotAdmin() bool {
+	if !ctx.Message.IsGroup {
+		return false
+	}
+	isAdmin, err := ctx.Client.IsBotGroupAdmin(ctx.Message.From)
+	return err == nil && isAdmin
+}
+
+func (ctx *Context) IsOwner() bool {
+	owner := strings.TrimPrefix(ctx.Client.GetConfig().Bot.Owner, "+")
+	if owner == "" {
+		return false
+	}
+	for _, jid := range ctx.Message.SenderJIDs() {
+		if jid.User == owner {
+			return true
+		}
+	}
+	return false
+}
+
 func (ctx *Context) GetSender() string {
 	return ctx.Message.Sender.String()
 }
@@ -97,10 +256,14 @@ func (p *BasePlugin) Execute(ctx *Context) error {
 type Manager struct {
 	client      *whatsapp.Client
 	database    *database.Database
+	scheduler   *scheduler.Scheduler
+	sessions    SessionController
 	plugins     map[string]Plugin
 	beforePlugins []Plugin
 	allPlugins    []Plugin
 	afterPlugins  []Plugin
+	eventPlugins  []Plugin
+	groupEventPlugins []Plugin
 	prefix      string
 }
 
@@ -112,20 +275,64 @@ func NewManager(client *whatsapp.Client, db *database.Database) *Manager {
 		beforePlugins: []Plugin{},
 		allPlugins:    []Plugin{},
 		afterPlugins:  []Plugin{},
-		prefix:      "!",
+		eventPlugins:  []Plugin{},
+		groupEventPlugins: []Plugin{},
+		prefix:      client.GetConfig().SessionPrefix(client.Session()),
 	}
 }
 
 func (m *Manager) LoadPlugins() error {
     // Register plugin
 	m.registerPlugin(NewPingPlugin())
+	m.registerPlugin(NewStickerPlugin())
+	m.registerPlugin(NewPollPlugin())
+	m.registerPlugin(NewPollVotePlugin())
+	m.registerPlugin(NewGroupPlugin())
+	m.registerPlugin(NewKickPlugin())
+	m.registerPlugin(NewAddPlugin())
+	m.registerPlugin(NewPromotePlugin())
+	m.registerPlugin(NewDemotePlugin())
+	m.registerPlugin(NewWelcomePlugin())
+	m.registerPlugin(NewGoodbyePlugin())
+	m.registerPlugin(NewGreetingPlugin())
+	m.registerPlugin(NewModerationPlugin())
+	m.registerPlugin(NewModerationConfigPlugin())
+	m.registerPlugin(NewRemindPlugin())
+	m.registerPlugin(NewSchedulePlugin())
+	m.registerPlugin(NewBroadcastPlugin())
+	m.registerPlugin(NewSessionPlugin())
 	//m.registerPlugin(&SpeedTestPlugin{})
 	
 	logger.Info("📦 Loaded %d plugins", len(m.plugins))
 	return nil
 }
 
+// isEnabled applies plugins.disabled_list and the plugin lists of the
+// session this manager serves.
+func (m *Manager) isEnabled(plugin Plugin) bool {
+	cfg := m.client.GetConfig()
+	session := m.client.Session()
+	
+	listed := func(list []string) bool {
+		for _, name := range list {
+			if strings.EqualFold(name, plugin.Name()) {
+				return true
+			}
+		}
+		return false
+	}
+	
+	if listed(cfg.Plugins.DisabledList) || listed(session.DisabledPlugins) {
+		return false
+	}
+	return len(session.Plugins) == 0 || listed(session.Plugins)
+}
+
 func (m *Manager) registerPlugin(plugin Plugin) {
+	if !m.isEnabled(plugin) {
+		return
+	}
+	
 	name := strings.ToLower(plugin.Name())
 	m.plugins[name] = plugin
 	
@@ -133,15 +340,17 @@ func (m *Manager) registerPlugin(plugin Plugin) {
 		m.plugins[strings.ToLower(alias)] = plugin
 	}
 	
-	if basePlugin, ok := plugin.(*BasePlugin); ok {
-		switch basePlugin.PluginType {
-		case PluginTypeBefore:
-			m.beforePlugins = append(m.beforePlugins, plugin)
-		case PluginTypeAll:
-			m.allPlugins = append(m.allPlugins, plugin)
-		case PluginTypeAfter:
-			m.afterPlugins = append(m.afterPlugins, plugin)
-		}
+	switch plugin.Type() {
+	case PluginTypeBefore:
+		m.beforePlugins = append(m.beforePlugins, plugin)
+	case PluginTypeAll:
+		m.allPlugins = append(m.allPlugins, plugin)
+	case PluginTypeAfter:
+		m.afterPlugins = append(m.afterPlugins, plugin)
+	case PluginTypeEvent:
+		m.eventPlugins = append(m.eventPlugins, plugin)
+	case PluginTypeGroupEvent:
+		m.groupEventPlugins = append(m.groupEventPlugins, plugin)
 	}
 	
 	logger.PluginLoaded(plugin.Name())
@@ -152,14 +361,24 @@ func (m *Manager) HandleMessage(msg *whatsapp.Message) error {
 		return nil
 	}
 	
-	ctx := &Context{
-		Client:   m.client,
-		Database: m.database,
-		Message:  msg,
-		Body:     msg.Body,
-		Prefix:   m.prefix,
+	ctx := m.newContext(msg)
+	
+	if msg.IsEvent() {
+		// An edit can add a link or a banned word to a message that passed
+		// the checks, so the before plugins see the edited body too.
+		if msg.Edit != nil && !m.client.IsPassive() && !m.runBefore(ctx) {
+			return nil
+		}
+		for _, plugin := range m.eventPlugins {
+			if err := plugin.Execute(ctx); err != nil {
+				logger.Error("Event plugin %s failed: %v", plugin.Name(), err)
+			}
+		}
+		return nil
 	}
 	
+	ctx.User = m.registerUser(ctx)
+	
 	if whatsapp.IsCommand(msg.Body, m.prefix) {
 		ctx.IsCommand = true
 		cmd, args := whatsapp.ExtractCommand(msg.Body, m.prefix)
@@ -167,28 +386,18 @@ func (m *Manager) HandleMessage(msg *whatsapp.Message) error {
 		ctx.Args = args
 	}
 	
-	for _, plugin := range m.beforePlugins {
-		if err := plugin.Execute(ctx); err != nil {
-			logger.Error("Before plugin %s failed: %v", plugin.Name(), err)
-		}
+	m.markRead(ctx)
+	
+	if m.client.IsPassive() {
+		return nil
 	}
 	
-	if ctx.IsCommand && ctx.Command != "" {
-		if plugin, exists := m.plugins[ctx.Command]; exists {
-			if basePlugin, ok := plugin.(*BasePlugin); ok && basePlugin.NoPrefix {
-				logger.PluginExecuted(plugin.Name(), ctx.GetSenderUser())
-				if err := plugin.Execute(ctx); err != nil {
-					logger.Error("Plugin %s failed: %v", plugin.Name(), err)
-					return ctx.Reply(fmt.Sprintf("❌ Error: %v", err))
-				}
-			} else {
-				logger.PluginExecuted(plugin.Name(), ctx.GetSenderUser())
-				if err := plugin.Execute(ctx); err != nil {
-					logger.Error("Plugin %s failed: %v", plugin.Name(), err)
-					return ctx.Reply(fmt.Sprintf("❌ Error: %v", err))
-				}
-			}
-		}
+	if !m.runBefore(ctx) {
+		return nil
+	}
+	
+	if err := m.runCommand(ctx); err != nil {
+		return ctx.Reply(fmt.Sprintf("❌ Error: %v", err))
 	}
 	
 	for _, plugin := range m.allPlugins {
@@ -206,6 +415,175 @@ func (m *Manager) HandleMessage(msg *whatsapp.Message) error {
 	return nil
 }
 
+// runBefore runs the before plugins and reports whether the message should
+// be handled further.
+func (m *Manager) runBefore(ctx *Context) bool {
+	for _, plugin := range m.beforePlugins {
+		if err := plugin.Execute(ctx); err != nil {
+			logger.Error("Before plugin %s failed: %v", plugin.Name(), err)
+		}
+		if ctx.IsStopped() {
+			return false
+		}
+	}
+	return true
+}
+
+// markRead sends read receipts according to whatsapp.auto_read: "all",
+// "commands" for known commands only, or "none".
+func (m *Manager) markRead(ctx *Context) {
+	mode := m.client.GetConfig().WhatsApp.AutoRead
+	switch mode {
+	case "all":
+	case "commands":
+		plugin, exists := m.plugins[ctx.Command]
+		if !ctx.IsCommand || !exists || plugin.Type() != PluginTypeCommand {
+			return
+		}
+	default:
+		return
+	}
+	
+	if err := m.client.MarkRead(ctx.Message); err != nil {
+		logger.Error("Failed to mark message as read: %v", err)
+	}
+}
+
+// registerUser loads the sender's record, creating it on their first
+// message. The name follows their push name. Records are keyed without the
+// device, so phone and linked devices share one.
+func (m *Manager) registerUser(ctx *Context) *database.User {
+	jid := ctx.Message.Sender.ToNonAD().String()
+	user, err := m.database.LoadUser(jid)
+	if err != nil {
+		logger.Error("Failed to load user: %v", err)
+		return database.NewUser(jid)
+	}
+	
+	var name string
+	if ctx.Message.Raw != nil {
+		name = ctx.Message.Raw.Info.PushName
+	}
+	if !user.IsNew() && (name == "" || name == user.Name) {
+		return user
+	}
+	
+	if name != "" {
+		user.Name = name
+	}
+	if user.IsNew() && ctx.IsOwner() {
+		user.Role = database.RoleOwner
+	}
+	if err := m.database.SaveUser(user); err != nil {
+		logger.Error("Failed to save user %s: %v", user.JID, err)
+	}
+	return user
+}
+
+func (m *Manager) newContext(msg *whatsapp.Message) *Context {
+	return &Context{
+		Client:    m.client,
+		Database:  m.database,
+		Scheduler: m.scheduler,
+		Sessions:  m.sessions,
+		Account:   m.client.Session().Name,
+		Message:   msg,
+		Body:      msg.Body,
+		Prefix:    m.prefix,
+	}
+}
+
+func (m *Manager) runCommand(ctx *Context) error {
+	if !ctx.IsCommand || ctx.Command == "" {
+		return nil
+	}
+	
+	plugin, exists := m.plugins[ctx.Command]
+	if !exists || plugin.Type() != PluginTypeCommand {
+		return nil
+	}
+	
+	logger.PluginExecuted(plugin.Name(), ctx.GetSenderUser())
+	// Replies are sent after the command returns, so stopping here would
+	// pause typing just before the queue shows it again for the reply.
+	if plugin.ShowsTyping() && !ctx.Client.GetConfig().Queue.Typing {
+		stop := ctx.Client.StartTyping(ctx.Message.From, false)
+		defer stop()
+	}
+	if err := plugin.Execute(ctx); err != nil {
+		logger.Error("Plugin %s failed: %v", plugin.Name(), err)
+		return err
+	}
+	
+	return nil
+}
+
+// RunJob is the scheduler runner. Command jobs run on behalf of their
+// creator, anything else is sent as is.
+func (m *Manager) RunJob(job *scheduler.Job) error {
+	chat, err := types.ParseJID(job.Chat)
+	if err != nil {
+		return err
+	}
+	
+	if !jobRunsCommand(job, m.prefix) {
+		mentions := make([]types.JID, 0, len(job.Mentions))
+		for _, mention := range job.Mentions {
+			if jid, err := types.ParseJID(mention); err == nil {
+				mentions = append(mentions, jid)
+			}
+		}
+		return m.client.SendMessageWithMentions(chat, job.Text, mentions)
+	}
+	
+	creator, err := types.ParseJID(job.Creator)
+	if err != nil {
+		return err
+	}
+	
+	ctx := m.newContext(&whatsapp.Message{
+		From:      chat,
+		Sender:    creator,
+		Body:      job.Text,
+		Type:      "text",
+		IsGroup:   chat.Server == types.GroupServer,
+		Timestamp: time.Now(),
+	})
+	ctx.IsCommand = true
+	ctx.Command, ctx.Args = whatsapp.ExtractCommand(job.Text, m.prefix)
+	
+	if err := m.runCommand(ctx); err != nil {
+		return ctx.Reply(fmt.Sprintf("❌ Error: %v", err))
+	}
+	
+	return nil
+}
+
+// HandleGroupEvent runs group event plugins. The context carries a synthetic
+// message from the group and actor so Send and the group helpers work.
+func (m *Manager) HandleGroupEvent(evt *whatsapp.GroupEvent) error {
+	ctx := m.newContext(&whatsapp.Message{
+		From:      evt.Group,
+		Sender:    evt.Actor,
+		Type:      "group_" + evt.Type,
+		IsGroup:   true,
+		Timestamp: evt.Timestamp,
+	})
+	ctx.GroupEvent = evt
+	
+	if m.client.IsPassive() {
+		return nil
+	}
+	
+	for _, plugin := range m.groupEventPlugins {
+		if err := plugin.Execute(ctx); err != nil {
+			logger.Error("Group event plugin %s failed: %v", plugin.Name(), err)
+		}
+	}
+	
+	return nil
+}
+
 func (m *Manager) GetPlugin(name string) (Plugin, bool) {
 	plugin, exists := m.plugins[strings.ToLower(name)]
 	return plugin, exists
@@ -225,6 +603,28 @@ func (m *Manager) GetPluginsByCategory(category string) []Plugin {
 	return plugins
 }
 
+func (m *Manager) SetScheduler(s *scheduler.Scheduler) {
+	m.scheduler = s
+	s.SetRunner(m.RunJob)
+}
+
+func (m *Manager) SetSessions(sessions SessionController) {
+	m.sessions = sessions
+}
+
+// jobRunsCommand tells command jobs from messages. Jobs saved before kinds
+// existed run as a command when they start with the bot prefix itself,
+// not with any symbol like incoming messages.
+func jobRunsCommand(job *scheduler.Job, prefix string) bool {
+	switch job.Kind {
+	case scheduler.KindCommand:
+		return true
+	case scheduler.KindMessage:
+		return false
+	}
+	return whatsapp.HasPrefix(job.Text, prefix)
+}
+
 func (m *Manager) SetPrefix(prefix string) {
 	m.prefix = prefix
 }
\ No newline at end of file
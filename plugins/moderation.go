@@ -0,0 +1,290 @@
+package plugins
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"yukii-bot/lib/logger"
+	"yukii-bot/lib/moderation"
+	"yukii-bot/lib/whatsapp"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+type ModerationPlugin struct {
+	BasePlugin
+	flood *moderation.FloodTracker
+}
+
+func NewModerationPlugin() *ModerationPlugin {
+	return &ModerationPlugin{
+		BasePlugin: BasePlugin{
+			PluginName:        "Moderation",
+			PluginDescription: "Enforce anti-link, anti-flood and word filters in groups",
+			PluginCategory:    "Group",
+			PluginType:        PluginTypeBefore,
+		},
+		flood: moderation.NewFloodTracker(),
+	}
+}
+
+func (p *ModerationPlugin) Execute(ctx *Context) error {
+	if !ctx.IsGroup() {
+		return nil
+	}
+
+	group := ctx.Message.From.String()
+	settings := moderation.LoadSettings(ctx.Database, group)
+	if !settings.Enabled() {
+		return nil
+	}
+
+	violation, detail := p.detect(ctx, settings)
+	if violation == "" {
+		return nil
+	}
+
+	if ctx.IsGroupAdmin() || ctx.IsOwner() {
+		return nil
+	}
+
+	ctx.Stop()
+	return p.enforce(ctx, settings, violation, detail)
+}
+
+func (p *ModerationPlugin) detect(ctx *Context, settings moderation.Settings) (string, string) {
+	body := ctx.Message.Body
+
+	// Edits don't count towards flooding, only their new text is checked.
+	if settings.AntiFlood && ctx.Message.Edit == nil {
+		key := ctx.Message.From.String() + "/" + ctx.Client.SenderPhoneJID(ctx.Message).String()
+		if p.flood.Hit(key, settings.FloodLimit, settings.FloodWindow) {
+			p.flood.Reset(key)
+			return moderation.ViolationFlood, ""
+		}
+	}
+	if settings.AntiLink && moderation.ContainsInviteLink(body) {
+		return moderation.ViolationLink, ""
+	}
+	if settings.AntiWords {
+		if word, found := moderation.FindBannedWord(body, settings.Words); found {
+			return moderation.ViolationWord, word
+		}
+	}
+	return "", ""
+}
+
+func (p *ModerationPlugin) enforce(ctx *Context, settings moderation.Settings, violation, detail string) error {
+	group := ctx.Message.From
+	sender := ctx.Message.Sender
+	botAdmin := ctx.IsBotAdmin()
+
+	logger.Warning("🛡️ %s in %s by %s", violation, group.User, sender.User)
+
+	if botAdmin {
+		if err := ctx.Revoke(); err != nil {
+			logger.Error("Failed to delete message: %v", err)
+		}
+	}
+
+	reason := violation
+	if detail != "" {
+		reason = fmt.Sprintf("%s (%s)", violation, detail)
+	}
+
+	switch settings.Action {
+	case moderation.ActionDelete:
+		return nil
+	case moderation.ActionKick:
+		return p.kick(ctx, reason, botAdmin)
+	}
+
+	// Warnings follow the phone number, so a user writing from their LID
+	// doesn't start over.
+	user := ctx.Client.SenderPhoneJID(ctx.Message).String()
+	warnings, err := moderation.AddWarning(ctx.Database, group.String(), user)
+	if err != nil {
+		return err
+	}
+
+	if settings.MaxWarnings > 0 && warnings >= settings.MaxWarnings {
+		if err := moderation.ResetWarnings(ctx.Database, group.String(), user); err != nil {
+			return err
+		}
+		return p.kick(ctx, reason, botAdmin)
+	}
+
+	limit := "∞"
+	if settings.MaxWarnings > 0 {
+		limit = strconv.Itoa(settings.MaxWarnings)
+	}
+	text := fmt.Sprintf("⚠️ %s, %s is not allowed here. Warning %d/%s",
+		whatsapp.MentionText(sender), reason, warnings, limit)
+	return ctx.SendWithMentions(text, []types.JID{sender})
+}
+
+func (p *ModerationPlugin) kick(ctx *Context, reason string, botAdmin bool) error {
+	sender := ctx.Message.Sender
+	if !botAdmin {
+		return ctx.SendWithMentions(fmt.Sprintf("⚠️ %s would be removed for %s, but I'm not a group admin",
+			whatsapp.MentionText(sender), reason), []types.JID{sender})
+	}
+
+	if err := ctx.SendWithMentions(fmt.Sprintf("🚫 %s was removed for %s",
+		whatsapp.MentionText(sender), reason), []types.JID{sender}); err != nil {
+		logger.Error("Failed to announce kick: %v", err)
+	}
+
+	_, err := ctx.Client.KickParticipants(ctx.Message.From, sender)
+	return err
+}
+
+type ModerationConfigPlugin struct {
+	BasePlugin
+}
+
+func NewModerationConfigPlugin() *ModerationConfigPlugin {
+	return &ModerationConfigPlugin{
+		BasePlugin: BasePlugin{
+			PluginName:        "Mod",
+			PluginDescription: "Configure group moderation",
+			PluginUsage:       "mod [antilink|antiflood|antiwords on|off] [words add|del|list <word>] [flood <count> <seconds>] [action warn|delete|kick] [maxwarn <n>] [warnings|resetwarn @user]",
+			PluginCategory:    "Group",
+			PluginAliases:     []string{"moderation"},
+			NoPrefix:          false,
+			PluginType:        PluginTypeCommand,
+		},
+	}
+}
+
+func (p *ModerationConfigPlugin) Execute(ctx *Context) error {
+	if !ctx.IsGroup() {
+		return ctx.Reply("❌ This command can only be used in groups")
+	}
+	if !ctx.IsGroupAdmin() && !ctx.IsOwner() {
+		return ctx.Reply("❌ This command is for group admins only")
+	}
+
+	group := ctx.Message.From.String()
+	settings := moderation.LoadSettings(ctx.Database, group)
+	option := strings.ToLower(ctx.GetArg(0))
+	value := strings.ToLower(ctx.GetArg(1))
+
+	switch option {
+	case "":
+		return ctx.Reply(p.status(settings))
+	case "antilink", "antiflood", "antispam", "antiwords":
+		if value != "on" && value != "off" {
+			return ctx.Reply(fmt.Sprintf("❌ Usage: *%smod %s on|off*", ctx.Prefix, option))
+		}
+		enabled := value == "on"
+		switch option {
+		case "antilink":
+			settings.AntiLink = enabled
+		case "antiflood", "antispam":
+			settings.AntiFlood = enabled
+		case "antiwords":
+			settings.AntiWords = enabled
+		}
+	case "words":
+		words := ctx.Args[min(2, len(ctx.Args)):]
+		switch value {
+		case "add":
+			settings.Words = append(settings.Words, words...)
+		case "del", "remove":
+			settings.Words = removeWords(settings.Words, words)
+		default:
+			if len(settings.Words) == 0 {
+				return ctx.Reply("📝 No banned words")
+			}
+			return ctx.Reply("📝 *Banned words:* " + strings.Join(settings.Words, ", "))
+		}
+	case "flood":
+		count, errCount := strconv.Atoi(ctx.GetArg(1))
+		seconds, errSeconds := strconv.Atoi(ctx.GetArg(2))
+		if errCount != nil || errSeconds != nil || count < 1 || seconds < 1 {
+			return ctx.Reply(fmt.Sprintf("❌ Usage: *%smod flood <count> <seconds>*", ctx.Prefix))
+		}
+		settings.FloodLimit = count
+		settings.FloodWindow = time.Duration(seconds) * time.Second
+	case "action":
+		if value != moderation.ActionWarn && value != moderation.ActionDelete && value != moderation.ActionKick {
+			return ctx.Reply(fmt.Sprintf("❌ Usage: *%smod action warn|delete|kick*", ctx.Prefix))
+		}
+		settings.Action = value
+	case "maxwarn":
+		count, err := strconv.Atoi(value)
+		if err != nil || count < 0 {
+			return ctx.Reply(fmt.Sprintf("❌ Usage: *%smod maxwarn <n>* (0 never kicks)", ctx.Prefix))
+		}
+		settings.MaxWarnings = count
+	case "warnings", "resetwarn":
+		targets := targetUsers(ctx)
+		if len(targets) == 0 {
+			return ctx.Reply(fmt.Sprintf("❌ Usage: *%smod %s @user*", ctx.Prefix, option))
+		}
+		if option == "resetwarn" {
+			for _, target := range targets {
+				if err := moderation.ResetWarnings(ctx.Database, group, ctx.Client.PhoneJID(target).String()); err != nil {
+					return err
+				}
+			}
+			return ctx.React("✅")
+		}
+		var lines []string
+		for _, target := range targets {
+			lines = append(lines, fmt.Sprintf("%s: %d/%d", whatsapp.MentionText(target),
+				moderation.GetWarnings(ctx.Database, group, ctx.Client.PhoneJID(target).String()), settings.MaxWarnings))
+		}
+		return ctx.ReplyWithMentions("⚠️ *Warnings*\n"+strings.Join(lines, "\n"), targets)
+	default:
+		return ctx.Reply(fmt.Sprintf("🛡️ Usage: *%s%s*", ctx.Prefix, p.Usage()))
+	}
+
+	if err := moderation.SaveSettings(ctx.Database, group, settings); err != nil {
+		return err
+	}
+	return ctx.React("✅")
+}
+
+func (p *ModerationConfigPlugin) status(settings moderation.Settings) string {
+	onOff := func(enabled bool) string {
+		if enabled {
+			return "On"
+		}
+		return "Off"
+	}
+
+	return fmt.Sprintf(`🛡️ *Moderation*
+
+🔗 *Anti-link:* %s
+🌊 *Anti-flood:* %s (%d msgs / %v)
+🤬 *Word filter:* %s (%d words)
+
+⚖️ *Action:* %s
+⚠️ *Kick after:* %d warnings`,
+		onOff(settings.AntiLink),
+		onOff(settings.AntiFlood), settings.FloodLimit, settings.FloodWindow,
+		onOff(settings.AntiWords), len(settings.Words),
+		settings.Action,
+		settings.MaxWarnings)
+}
+
+func removeWords(words, remove []string) []string {
+	var result []string
+	for _, word := range words {
+		keep := true
+		for _, r := range remove {
+			if strings.EqualFold(word, r) {
+				keep = false
+				break
+			}
+		}
+		if keep {
+			result = append(result, word)
+		}
+	}
+	return result
+}
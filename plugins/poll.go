@@ -0,0 +1,230 @@
+package plugins
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"yukii-bot/lib/database"
+	"yukii-bot/lib/whatsapp"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+type PollTally struct {
+	ID         string
+	Chat       string
+	Question   string
+	Options    []string
+	Counts     map[string]int
+	TotalVotes int
+	Voters     int
+}
+
+// SavePoll persists a poll the bot created so incoming votes can be tallied.
+func SavePoll(db *database.Database, chat, id, question string, options []string, selectableCount int) error {
+	return db.Set(pollKey(id), map[string]interface{}{
+		"chat":       chat,
+		"question":   question,
+		"options":    options,
+		"selectable": selectableCount,
+		"created":    time.Now().Unix(),
+		"votes":      map[string]interface{}{},
+	})
+}
+
+// RecordPollVote stores the voter's current selection. WhatsApp sends the
+// full selection with every vote, so it replaces any previous one.
+func RecordPollVote(db *database.Database, vote *whatsapp.PollVote) error {
+	if !vote.Decrypted {
+		return nil
+	}
+
+	key := pollKey(vote.Poll.ID)
+	if !db.Has(key) {
+		return nil
+	}
+
+	var options []string
+	for _, option := range db.GetArray(key + ".options") {
+		options = append(options, option.String())
+	}
+
+	// The voter is already a phone number JID where WhatsApp tells it, so a
+	// vote sent from a LID replaces the one sent from the number.
+	voterKey := key + ".votes." + database.EscapeKey(vote.Voter.ToNonAD().String())
+	selected := vote.Selected(options)
+	if len(selected) == 0 {
+		return db.Delete(voterKey)
+	}
+	return db.Set(voterKey, selected)
+}
+
+func TallyPoll(db *database.Database, id string) (*PollTally, error) {
+	key := pollKey(id)
+	if !db.Has(key) {
+		return nil, fmt.Errorf("poll %s not found", id)
+	}
+
+	tally := &PollTally{
+		ID:       id,
+		Chat:     db.GetString(key + ".chat"),
+		Question: db.GetString(key + ".question"),
+		Counts:   make(map[string]int),
+	}
+	for _, option := range db.GetArray(key + ".options") {
+		tally.Options = append(tally.Options, option.String())
+		tally.Counts[option.String()] = 0
+	}
+
+	votes := db.GetMap(key + ".votes")
+	for voter, selection := range votes {
+		// Older votes were keyed by the bare number; skip one when the voter
+		// has voted again since.
+		if !strings.Contains(voter, "@") && votes[voter+"@"+types.DefaultUserServer].Exists() {
+			continue
+		}
+		tally.Voters++
+		for _, option := range selection.Array() {
+			if _, ok := tally.Counts[option.String()]; ok {
+				tally.Counts[option.String()]++
+				tally.TotalVotes++
+			}
+		}
+	}
+
+	return tally, nil
+}
+
+func pollKey(id string) string {
+	return "polls." + database.EscapeKey(id)
+}
+
+// LatestPoll returns the ID of the most recent poll created in chat.
+func LatestPoll(db *database.Database, chat string) (string, bool) {
+	var latestID string
+	var latest int64
+	for id, poll := range db.GetMap("polls") {
+		if poll.Get("chat").String() != chat {
+			continue
+		}
+		if created := poll.Get("created").Int(); latestID == "" || created > latest {
+			latestID, latest = id, created
+		}
+	}
+	return latestID, latestID != ""
+}
+
+func (t *PollTally) String() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("📊 *%s*\n\n", t.Question))
+
+	options := make([]string, len(t.Options))
+	copy(options, t.Options)
+	sort.SliceStable(options, func(i, j int) bool {
+		return t.Counts[options[i]] > t.Counts[options[j]]
+	})
+
+	for _, option := range options {
+		count := t.Counts[option]
+		percent := 0
+		if t.TotalVotes > 0 {
+			percent = count * 100 / t.TotalVotes
+		}
+		bar := strings.Repeat("█", percent/10) + strings.Repeat("░", 10-percent/10)
+		sb.WriteString(fmt.Sprintf("%s\n%s %d%% (%d)\n\n", option, bar, percent, count))
+	}
+
+	sb.WriteString(fmt.Sprintf("👥 *Voters:* %d", t.Voters))
+	return sb.String()
+}
+
+type PollPlugin struct {
+	BasePlugin
+}
+
+func NewPollPlugin() *PollPlugin {
+	return &PollPlugin{
+		BasePlugin: BasePlugin{
+			PluginName:        "Poll",
+			PluginDescription: "Create a poll or show live results",
+			PluginUsage:       "poll [multi] question | option 1 | option 2 ... | poll results",
+			PluginCategory:    "Group",
+			PluginAliases:     []string{"vote"},
+			NoPrefix:          false,
+			PluginType:        PluginTypeCommand,
+		},
+	}
+}
+
+func (p *PollPlugin) Execute(ctx *Context) error {
+	chat := ctx.Message.From.String()
+
+	if len(ctx.Args) == 0 || strings.EqualFold(ctx.GetArg(0), "results") {
+		id := ctx.Message.QuotedID
+		if id == "" || !ctx.Database.Has(pollKey(id)) {
+			var ok bool
+			if id, ok = LatestPoll(ctx.Database, chat); !ok {
+				return ctx.Reply(fmt.Sprintf("📊 No polls yet. Usage: *%s%s*", ctx.Prefix, p.Usage()))
+			}
+		}
+
+		tally, err := TallyPoll(ctx.Database, id)
+		if err != nil {
+			return err
+		}
+		return ctx.Reply(tally.String())
+	}
+
+	args := ctx.Args
+	selectable := 1
+	if strings.EqualFold(args[0], "multi") {
+		selectable = 0
+		args = args[1:]
+	}
+
+	var parts []string
+	for _, part := range strings.Split(strings.Join(args, " "), "|") {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	if len(parts) < 3 {
+		return ctx.Reply(fmt.Sprintf("📊 Usage: *%s%s*", ctx.Prefix, p.Usage()))
+	}
+
+	question, options := parts[0], parts[1:]
+	if len(options) > 12 {
+		return ctx.Reply("❌ A poll can have at most 12 options")
+	}
+
+	id, err := ctx.Client.SendPoll(ctx.Message.From, question, options, selectable)
+	if err != nil {
+		return err
+	}
+
+	return SavePoll(ctx.Database, chat, id, question, options, selectable)
+}
+
+type PollVotePlugin struct {
+	BasePlugin
+}
+
+func NewPollVotePlugin() *PollVotePlugin {
+	return &PollVotePlugin{
+		BasePlugin: BasePlugin{
+			PluginName:        "PollVote",
+			PluginDescription: "Record votes on polls created by the bot",
+			PluginCategory:    "Group",
+			PluginType:        PluginTypeEvent,
+		},
+	}
+}
+
+func (p *PollVotePlugin) Execute(ctx *Context) error {
+	if ctx.Message.PollVote == nil {
+		return nil
+	}
+	return RecordPollVote(ctx.Database, ctx.Message.PollVote)
+}
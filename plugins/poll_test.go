@@ -0,0 +1,58 @@
+package plugins
+
+import (
+	"path/filepath"
+	"testing"
+
+	"yukii-bot/lib/database"
+	"yukii-bot/lib/whatsapp"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+func TestPollVotesKeyedByJID(t *testing.T) {
+	db, err := database.Init(filepath.Join(t.TempDir(), "database.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	options := []string{"yes", "no"}
+	if err := SavePoll(db, "120363025246125888@g.us", "123", "Lunch?", options, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	vote := func(voter types.JID, option string) {
+		t.Helper()
+		err := RecordPollVote(db, &whatsapp.PollVote{
+			Poll:           whatsapp.MessageRef{ID: "123"},
+			Voter:          voter,
+			SelectedHashes: [][]byte{whatsapp.PollOptionHash(option)},
+			Decrypted:      true,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	phone := types.NewJID("6281234567890", types.DefaultUserServer)
+	vote(phone, "yes")
+	vote(types.NewADJID("6281234567890", 0, 12), "no")
+	// A LID the client couldn't map to a number counts as another voter.
+	vote(types.NewJID("6281234567890", types.HiddenUserServer), "yes")
+
+	// An old vote keyed by the bare number is replaced by the newer one.
+	if err := db.Set(pollKey("123")+".votes."+database.EscapeKey(phone.User), []string{"yes"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if !db.Get(pollKey("123")).IsObject() || !db.Get(pollKey("123")+".votes").IsObject() {
+		t.Fatalf("polls = %s, want objects", db.Get("polls").Raw)
+	}
+	tally, err := TallyPoll(db, "123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tally.Voters != 2 || tally.Counts["no"] != 1 || tally.Counts["yes"] != 1 {
+		t.Errorf("tally = %d voters, %v, want 2 voters, one vote each", tally.Voters, tally.Counts)
+	}
+}
@@ -0,0 +1,248 @@
+package plugins
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"yukii-bot/lib/scheduler"
+	"yukii-bot/lib/whatsapp"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+// parseWhen accepts a delay such as 10m, 1h30m or 2d, or a clock time like
+// 18:30 which means the next time that clock time comes around.
+func parseWhen(value string) (time.Time, error) {
+	now := time.Now()
+
+	if clock, err := time.ParseInLocation("15:04", value, time.Local); err == nil {
+		at := time.Date(now.Year(), now.Month(), now.Day(), clock.Hour(), clock.Minute(), 0, 0, time.Local)
+		if !at.After(now) {
+			at = at.AddDate(0, 0, 1)
+		}
+		return at, nil
+	}
+
+	var days int
+	if i := strings.Index(value, "d"); i > 0 {
+		n, err := strconv.Atoi(value[:i])
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid time %q", value)
+		}
+		days = n
+		value = value[i+1:]
+	}
+
+	var delay time.Duration
+	if value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid time %q", value)
+		}
+		delay = d
+	}
+	delay += time.Duration(days) * 24 * time.Hour
+
+	if delay <= 0 {
+		return time.Time{}, fmt.Errorf("the time must be in the future")
+	}
+	return now.Add(delay), nil
+}
+
+func formatJob(job *scheduler.Job) string {
+	when := job.RunAt.Format("2006-01-02 15:04")
+	if job.IsRecurring() {
+		when = fmt.Sprintf("%s (next %s)", job.Cron, when)
+	}
+
+	text := job.Text
+	if runes := []rune(text); len(runes) > 40 {
+		text = string(runes[:40]) + "…"
+	}
+	return fmt.Sprintf("*%s* ⏰ %s\n%s", job.ID, when, text)
+}
+
+type RemindPlugin struct {
+	BasePlugin
+}
+
+func NewRemindPlugin() *RemindPlugin {
+	return &RemindPlugin{
+		BasePlugin: BasePlugin{
+			PluginName:        "Remind",
+			PluginDescription: "Remind yourself about something later",
+			PluginUsage:       "remind <10m|2h|1d|18:30> <text>",
+			PluginCategory:    "Tools",
+			PluginAliases:     []string{"reminder", "remindme"},
+			NoPrefix:          false,
+			PluginType:        PluginTypeCommand,
+		},
+	}
+}
+
+func (p *RemindPlugin) Execute(ctx *Context) error {
+	if ctx.Scheduler == nil {
+		return fmt.Errorf("scheduler is not running")
+	}
+
+	text := ctx.GetRawArgs(1)
+	if text == "" {
+		return ctx.Reply(fmt.Sprintf("⏰ Usage: *%s%s*", ctx.Prefix, p.Usage()))
+	}
+
+	runAt, err := parseWhen(ctx.GetArg(0))
+	if err != nil {
+		return ctx.Reply("❌ " + err.Error())
+	}
+
+	sender := ctx.Message.Sender
+	job := reminderJob(ctx.Message.From, sender, text, runAt)
+	if err := ctx.Scheduler.Add(job); err != nil {
+		return err
+	}
+
+	return ctx.Reply(fmt.Sprintf("⏰ I'll remind you at %s (id *%s*)", runAt.Format("2006-01-02 15:04"), job.ID))
+}
+
+// reminderJob builds the message that reminds sender of text in chat.
+func reminderJob(chat, sender types.JID, text string, runAt time.Time) *scheduler.Job {
+	return &scheduler.Job{
+		Kind:     scheduler.KindMessage,
+		Chat:     chat.String(),
+		Creator:  sender.String(),
+		Text:     fmt.Sprintf("⏰ Reminder for %s:\n%s", whatsapp.MentionText(sender), text),
+		Mentions: []string{sender.String()},
+		RunAt:    runAt,
+	}
+}
+
+type SchedulePlugin struct {
+	BasePlugin
+}
+
+func NewSchedulePlugin() *SchedulePlugin {
+	return &SchedulePlugin{
+		BasePlugin: BasePlugin{
+			PluginName:        "Schedule",
+			PluginDescription: "Schedule one-off or recurring messages and commands",
+			PluginUsage:       "schedule [list|at <when> <text>|cron <expr> <text>|cancel <id>]",
+			PluginCategory:    "Tools",
+			PluginAliases:     []string{"sched"},
+			NoPrefix:          false,
+			PluginType:        PluginTypeCommand,
+		},
+	}
+}
+
+func (p *SchedulePlugin) Execute(ctx *Context) error {
+	if ctx.Scheduler == nil {
+		return fmt.Errorf("scheduler is not running")
+	}
+
+	switch strings.ToLower(ctx.GetArg(0)) {
+	case "", "list":
+		return p.list(ctx)
+	case "at", "in":
+		return p.add(ctx, false)
+	case "cron", "every":
+		return p.add(ctx, true)
+	case "cancel", "del", "delete":
+		return p.cancel(ctx, ctx.GetArg(1))
+	}
+
+	return ctx.Reply(fmt.Sprintf("⏰ Usage: *%s%s*\n\nTexts starting with *%s* run as a command.\nCron: *%sschedule cron 0 8 * * 1-5 Good morning!* or *@daily*, *@every 2h*",
+		ctx.Prefix, p.Usage(), ctx.Prefix, ctx.Prefix))
+}
+
+func (p *SchedulePlugin) add(ctx *Context, recurring bool) error {
+	if ctx.IsGroup() && !ctx.IsGroupAdmin() && !ctx.IsOwner() {
+		return ctx.Reply("❌ Only group admins can schedule messages here")
+	}
+
+	job := &scheduler.Job{
+		Chat:    ctx.Message.From.String(),
+		Creator: ctx.Message.Sender.String(),
+	}
+
+	if recurring {
+		// A cron spec is five fields, a single descriptor like @daily, or
+		// @every followed by a duration.
+		fields := 5
+		if spec := ctx.GetArg(1); strings.HasPrefix(spec, "@") {
+			fields = 1
+			if spec == "@every" {
+				fields = 2
+			}
+		}
+		if len(ctx.Args) < fields+1 {
+			return ctx.Reply(fmt.Sprintf("⏰ Usage: *%sschedule cron <expr> <text>*", ctx.Prefix))
+		}
+		job.Cron = strings.Join(ctx.Args[1:fields+1], " ")
+		job.Text = ctx.GetRawArgs(fields)
+	} else {
+		runAt, err := parseWhen(ctx.GetArg(1))
+		if err != nil {
+			return ctx.Reply("❌ " + err.Error())
+		}
+		job.RunAt = runAt
+		job.Text = ctx.GetRawArgs(1)
+	}
+
+	if job.Text == "" {
+		return ctx.Reply("❌ Please provide the text to send")
+	}
+	job.Kind = scheduler.KindMessage
+	if whatsapp.HasPrefix(job.Text, ctx.Prefix) {
+		job.Kind = scheduler.KindCommand
+	}
+
+	if err := ctx.Scheduler.Add(job); err != nil {
+		return err
+	}
+	return ctx.Reply("✅ Scheduled\n\n" + formatJob(job))
+}
+
+// list shows the owner every job; everyone else sees the jobs of this chat.
+func (p *SchedulePlugin) list(ctx *Context) error {
+	chat := ctx.Message.From.String()
+	var filter func(*scheduler.Job) bool
+	if !ctx.IsOwner() {
+		filter = func(job *scheduler.Job) bool {
+			return job.Chat == chat
+		}
+	}
+
+	jobs := ctx.Scheduler.List(filter)
+	if len(jobs) == 0 {
+		return ctx.Reply("⏰ Nothing scheduled")
+	}
+
+	lines := make([]string, 0, len(jobs))
+	for _, job := range jobs {
+		line := formatJob(job)
+		if job.Chat != chat {
+			line += "\n📍 " + job.Chat
+		}
+		lines = append(lines, line)
+	}
+	return ctx.Reply("⏰ *Scheduled*\n\n" + strings.Join(lines, "\n\n"))
+}
+
+func (p *SchedulePlugin) cancel(ctx *Context, id string) error {
+	job, exists := ctx.Scheduler.Get(id)
+	if !exists {
+		return ctx.Reply("❌ No scheduled job with that id")
+	}
+
+	if !ctx.IsOwner() && job.Creator != ctx.Message.Sender.String() &&
+		!(job.Chat == ctx.Message.From.String() && ctx.IsGroupAdmin()) {
+		return ctx.Reply("❌ You can only cancel your own schedules")
+	}
+
+	if err := ctx.Scheduler.Cancel(id); err != nil {
+		return err
+	}
+	return ctx.React("✅")
+}
@@ -0,0 +1,45 @@
+package plugins
+
+import (
+	"testing"
+	"time"
+
+	"yukii-bot/lib/scheduler"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+func TestReminderIsSentAsMessage(t *testing.T) {
+	chat := types.NewJID("120363025246125888", types.GroupServer)
+	sender := types.NewJID("6281234567890", types.DefaultUserServer)
+
+	job := reminderJob(chat, sender, "drink water", time.Now().Add(time.Hour))
+	if jobRunsCommand(job, "!") {
+		t.Errorf("reminder %q runs as a command", job.Text)
+	}
+
+	// Reminders saved before jobs had a kind start with an emoji, which
+	// incoming messages would take as a rich prefix.
+	job.Kind = ""
+	if jobRunsCommand(job, "!") {
+		t.Errorf("old reminder %q runs as a command", job.Text)
+	}
+}
+
+func TestScheduledCommandKind(t *testing.T) {
+	tests := []struct {
+		job  scheduler.Job
+		want bool
+	}{
+		{scheduler.Job{Kind: scheduler.KindCommand, Text: "!ping"}, true},
+		{scheduler.Job{Kind: scheduler.KindMessage, Text: "!ping"}, false},
+		{scheduler.Job{Text: "!ping"}, true},
+		{scheduler.Job{Text: "🎉 Happy new year"}, false},
+		{scheduler.Job{Text: "#general meeting"}, false},
+	}
+	for _, tt := range tests {
+		if got := jobRunsCommand(&tt.job, "!"); got != tt.want {
+			t.Errorf("kind %q text %q: runs command = %v, want %v", tt.job.Kind, tt.job.Text, got, tt.want)
+		}
+	}
+}
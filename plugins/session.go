@@ -0,0 +1,123 @@
+package plugins
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"yukii-bot/lib/config"
+	"yukii-bot/lib/logger"
+	"yukii-bot/lib/whatsapp"
+)
+
+type SessionPlugin struct {
+	BasePlugin
+}
+
+func NewSessionPlugin() *SessionPlugin {
+	return &SessionPlugin{
+		BasePlugin: BasePlugin{
+			PluginName:        "Session",
+			PluginDescription: "List, add or remove the WhatsApp accounts of this bot",
+			PluginUsage:       "session [list|add <name> [phone] [prefix]|remove <name>]",
+			PluginCategory:    "Owner",
+			PluginAliases:     []string{"sessions"},
+			NoPrefix:          false,
+			PluginType:        PluginTypeCommand,
+		},
+	}
+}
+
+func (p *SessionPlugin) Execute(ctx *Context) error {
+	if !ctx.IsOwner() {
+		return ctx.Reply("❌ This command is for the bot owner only")
+	}
+	if ctx.Sessions == nil {
+		return fmt.Errorf("sessions are not managed in this process")
+	}
+
+	switch strings.ToLower(ctx.GetArg(0)) {
+	case "", "list":
+		return p.list(ctx)
+	case "add":
+		name := strings.ToLower(ctx.GetArg(1))
+		if name == "" {
+			return ctx.Reply(fmt.Sprintf("📱 Usage: *%s%s*", ctx.Prefix, p.Usage()))
+		}
+		session := config.Session{
+			Name:   name,
+			Prefix: ctx.GetArg(3),
+		}
+		if phone := ctx.GetArg(2); phone != "" {
+			normalized, err := whatsapp.NormalizePhone(phone)
+			if err != nil {
+				return err
+			}
+			session.Phone = normalized
+		}
+
+		var onPair whatsapp.PairHandler
+		if session.Phone != "" {
+			onPair = p.pairNotifier(ctx, name)
+		}
+		if err := ctx.Sessions.AddSession(session, onPair); err != nil {
+			return err
+		}
+		if session.Phone != "" {
+			return ctx.Reply(fmt.Sprintf("📱 Session *%s* added, requesting a pairing code for +%s...", name, session.Phone))
+		}
+		return ctx.Reply(fmt.Sprintf("📱 Session *%s* added, scan the QR code shown in the terminal", name))
+	case "remove", "del", "delete":
+		name := strings.ToLower(ctx.GetArg(1))
+		if name == ctx.Account {
+			return ctx.Reply("❌ Remove this session from another account")
+		}
+		if err := ctx.Sessions.RemoveSession(name); err != nil {
+			return err
+		}
+		return ctx.React("✅")
+	}
+
+	return ctx.Reply(fmt.Sprintf("📱 Usage: *%s%s*", ctx.Prefix, p.Usage()))
+}
+
+// pairNotifier sends the pairing codes of a new session to the chat that
+// added it.
+func (p *SessionPlugin) pairNotifier(ctx *Context, name string) whatsapp.PairHandler {
+	chat := ctx.Message.From
+	return func(update whatsapp.PairUpdate) {
+		var text string
+		switch update.State {
+		case whatsapp.PairCodeIssued:
+			text = fmt.Sprintf("🔐 Pairing code for *%s*: *%s*\n\nEnter it in WhatsApp > Linked devices > Link with phone number within %v",
+				name, update.Code, time.Until(update.Expires).Round(time.Second))
+		case whatsapp.PairLinked:
+			text = fmt.Sprintf("✅ Session *%s* is linked", name)
+		case whatsapp.PairFailed:
+			text = fmt.Sprintf("❌ Pairing session *%s* failed: %v", name, update.Err)
+		default:
+			return
+		}
+		if _, err := ctx.Client.SendMessageWithPriority(chat, text, whatsapp.PriorityHigh); err != nil {
+			logger.Error("Failed to send pairing update: %v", err)
+		}
+	}
+}
+
+func (p *SessionPlugin) list(ctx *Context) error {
+	lines := []string{"📱 *Sessions*", ""}
+	for _, session := range ctx.Sessions.ListSessions() {
+		number := "not logged in"
+		if session.JID != "" {
+			number = "+" + session.JID
+		}
+
+		current := ""
+		if session.Name == ctx.Account {
+			current = " 👈"
+		}
+		lines = append(lines, fmt.Sprintf("*%s*%s\n%s • %s • prefix %s",
+			session.Name, current, number, session.State, session.Prefix))
+	}
+	return ctx.Reply(strings.Join(lines, "\n"))
+}
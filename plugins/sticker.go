@@ -0,0 +1,66 @@
+package plugins
+
+import (
+	"errors"
+	"strings"
+
+	"yukii-bot/lib/media"
+)
+
+type StickerPlugin struct {
+	BasePlugin
+}
+
+func NewStickerPlugin() *StickerPlugin {
+	return &StickerPlugin{
+		BasePlugin: BasePlugin{
+			PluginName:        "Sticker",
+			PluginDescription: "Convert an image, GIF or video into a sticker",
+			PluginUsage:       "sticker [pack|publisher] (send with or reply to an image/GIF/video)",
+			PluginCategory:    "Media",
+			PluginAliases:     []string{"s", "stiker"},
+			NoPrefix:          false,
+			PluginType:        PluginTypeCommand,
+			AutoTyping:        true,
+		},
+	}
+}
+
+func (p *StickerPlugin) Execute(ctx *Context) error {
+	source := ctx.Message.MediaMessage()
+	if source == nil {
+		return ctx.Reply("🖼️ Send or reply to an image, GIF or video with *" + ctx.Prefix + "sticker*")
+	}
+
+	cfg := ctx.Client.GetConfig()
+	meta := media.StickerMetadata{
+		PackName:  cfg.Sticker.PackName,
+		Publisher: cfg.Sticker.Publisher,
+	}
+	if len(ctx.Args) > 0 {
+		parts := strings.SplitN(strings.Join(ctx.Args, " "), "|", 2)
+		meta.PackName = strings.TrimSpace(parts[0])
+		if len(parts) > 1 {
+			meta.Publisher = strings.TrimSpace(parts[1])
+		}
+	}
+
+	data, mimetype, err := ctx.Client.DownloadMedia(source)
+	if err != nil {
+		return err
+	}
+
+	sticker, err := media.NewSticker(data, mimetype, meta)
+	switch {
+	case errors.Is(err, media.ErrNoFFmpeg):
+		return ctx.Reply("❌ Videos can't be turned into stickers here, ffmpeg isn't installed")
+	case errors.Is(err, media.ErrUnsupportedMedia):
+		return ctx.Reply("❌ Only images, GIFs and videos can be turned into stickers")
+	case errors.Is(err, media.ErrStickerTooLarge):
+		return ctx.Reply("❌ This is too big for a sticker, try a shorter or simpler one")
+	case err != nil:
+		return err
+	}
+
+	return ctx.Client.SendSticker(ctx.Message.From, sticker, ctx.Message)
+}
@@ -0,0 +1,164 @@
+package plugins
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"yukii-bot/lib/whatsapp"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+var defaultGreetings = map[string]string{
+	"welcome": "👋 Welcome {user} to *{group}*!\nWe are now {count} members.",
+	"goodbye": "👋 Goodbye {user}, *{group}* now has {count} members.",
+}
+
+// RenderGreeting fills a welcome/goodbye template. Supported placeholders are
+// {user}, {group}, {desc} and {count}.
+func RenderGreeting(template string, users []types.JID, info *types.GroupInfo) string {
+	tags := make([]string, 0, len(users))
+	for _, user := range users {
+		tags = append(tags, whatsapp.MentionText(user))
+	}
+
+	return strings.NewReplacer(
+		"{user}", strings.Join(tags, ", "),
+		"{group}", info.Name,
+		"{desc}", info.Topic,
+		"{count}", strconv.Itoa(len(info.Participants)),
+	).Replace(template)
+}
+
+type GreetingConfigPlugin struct {
+	BasePlugin
+	kind string
+}
+
+func newGreetingConfigPlugin(kind, description string) *GreetingConfigPlugin {
+	return &GreetingConfigPlugin{
+		BasePlugin: BasePlugin{
+			PluginName:        strings.ToUpper(kind[:1]) + kind[1:],
+			PluginDescription: description,
+			PluginUsage:       kind + " [on|off|set <text>|reset|test]",
+			PluginCategory:    "Group",
+			PluginAliases:     []string{},
+			NoPrefix:          false,
+			PluginType:        PluginTypeCommand,
+		},
+		kind: kind,
+	}
+}
+
+func NewWelcomePlugin() *GreetingConfigPlugin {
+	return newGreetingConfigPlugin("welcome", "Configure the welcome message for new members")
+}
+
+func NewGoodbyePlugin() *GreetingConfigPlugin {
+	return newGreetingConfigPlugin("goodbye", "Configure the goodbye message for leaving members")
+}
+
+func (p *GreetingConfigPlugin) template(ctx *Context) string {
+	if template := ctx.Database.GetGroup(ctx.Message.From.String(), p.kind+".message").String(); template != "" {
+		return template
+	}
+	return defaultGreetings[p.kind]
+}
+
+func (p *GreetingConfigPlugin) Execute(ctx *Context) error {
+	if !ctx.IsGroup() {
+		return ctx.Reply("❌ This command can only be used in groups")
+	}
+
+	group := ctx.Message.From.String()
+	action := strings.ToLower(ctx.GetArg(0))
+
+	if action == "" {
+		status := "Off"
+		if ctx.Database.GetGroup(group, p.kind+".enabled").Bool() {
+			status = "On"
+		}
+		return ctx.Reply(fmt.Sprintf("👋 *%s:* %s\n\n%s\n\nPlaceholders: {user}, {group}, {desc}, {count}\nUsage: *%s%s*",
+			p.Name(), status, p.template(ctx), ctx.Prefix, p.Usage()))
+	}
+
+	if !ctx.IsGroupAdmin() && !ctx.IsOwner() {
+		return ctx.Reply("❌ This command is for group admins only")
+	}
+
+	var err error
+	switch action {
+	case "on":
+		err = ctx.Database.SetGroup(group, p.kind+".enabled", true)
+	case "off":
+		err = ctx.Database.SetGroup(group, p.kind+".enabled", false)
+	case "set":
+		text := ctx.GetRawArgs(1)
+		if text == "" {
+			return ctx.Reply("❌ Please provide the message text")
+		}
+		err = ctx.Database.SetGroup(group, p.kind+".message", text)
+	case "reset":
+		err = ctx.Database.SetGroup(group, p.kind+".message", "")
+	case "test":
+		info, err := ctx.GetGroupInfo()
+		if err != nil {
+			return err
+		}
+		return ctx.SendWithMentions(RenderGreeting(p.template(ctx), []types.JID{ctx.Message.Sender}, info), []types.JID{ctx.Message.Sender})
+	default:
+		return ctx.Reply(fmt.Sprintf("👋 Usage: *%s%s*", ctx.Prefix, p.Usage()))
+	}
+	if err != nil {
+		return err
+	}
+
+	return ctx.React("✅")
+}
+
+type GreetingPlugin struct {
+	BasePlugin
+}
+
+func NewGreetingPlugin() *GreetingPlugin {
+	return &GreetingPlugin{
+		BasePlugin: BasePlugin{
+			PluginName:        "Greeting",
+			PluginDescription: "Send welcome and goodbye messages",
+			PluginCategory:    "Group",
+			PluginType:        PluginTypeGroupEvent,
+		},
+	}
+}
+
+func (p *GreetingPlugin) Execute(ctx *Context) error {
+	evt := ctx.GroupEvent
+
+	var kind string
+	switch evt.Type {
+	case whatsapp.GroupEventJoin:
+		kind = "welcome"
+	case whatsapp.GroupEventLeave:
+		kind = "goodbye"
+	default:
+		return nil
+	}
+
+	group := evt.Group.String()
+	if !ctx.Database.GetGroup(group, kind+".enabled").Bool() {
+		return nil
+	}
+
+	template := ctx.Database.GetGroup(group, kind+".message").String()
+	if template == "" {
+		template = defaultGreetings[kind]
+	}
+
+	info, err := ctx.Client.GetGroupInfo(evt.Group)
+	if err != nil {
+		return err
+	}
+
+	return ctx.SendWithMentions(RenderGreeting(template, evt.Users, info), evt.Users)
+}